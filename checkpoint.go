@@ -0,0 +1,117 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// checkpoints remembers the resource version of every watch, so that a
+// watch that is started again, e.g. when its namespace is selected again,
+// resumes where the previous watch stopped. With a storage client, the
+// resource versions are persisted and survive restarts of the collector.
+type checkpoints struct {
+	mu       sync.Mutex
+	versions map[string]string
+	// dirty holds the keys whose resource version is not persisted yet.
+	dirty  map[string]bool
+	client storage.Client
+}
+
+func newCheckpoints() *checkpoints {
+	return &checkpoints{
+		versions: make(map[string]string),
+		dirty:    make(map[string]bool),
+	}
+}
+
+// get returns the resource version of a watch, if any. Resource versions
+// that are not known yet are read from storage.
+func (c *checkpoints) get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resourceVersion, ok := c.versions[key]; ok {
+		return resourceVersion, true, nil
+	}
+	if c.client == nil {
+		return "", false, nil
+	}
+	data, err := c.client.Get(ctx, key)
+	if err != nil || data == nil {
+		return "", false, err
+	}
+	c.versions[key] = string(data)
+	return string(data), true, nil
+}
+
+// set records the resource version of a watch, empty versions are ignored.
+// It is only persisted by the next flush.
+func (c *checkpoints) set(key string, resourceVersion string) {
+	if resourceVersion == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions[key] == resourceVersion {
+		return
+	}
+	c.versions[key] = resourceVersion
+	c.dirty[key] = true
+}
+
+// flush persists the resource versions that changed since the last flush.
+func (c *checkpoints) flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil || len(c.dirty) == 0 {
+		return nil
+	}
+	ops := make([]storage.Operation, 0, len(c.dirty))
+	for key := range c.dirty {
+		ops = append(ops, storage.SetOperation(key, []byte(c.versions[key])))
+	}
+	if err := c.client.Batch(ctx, ops...); err != nil {
+		return err
+	}
+	c.dirty = make(map[string]bool)
+	return nil
+}
+
+// checkpointKey identifies the watch of a resource in a namespace with
+// the selectors of its object, so that objects watching the same resource
+// with different selectors resume from their own resource version. Keys of
+// watches without selectors have no query.
+func checkpointKey(clusterName string, gvr schema.GroupVersionResource, namespace, labelSelector, fieldSelector string) string {
+	key := clusterName + "/" + gvr.String() + "/" + namespace
+	selectors := url.Values{}
+	if labelSelector != "" {
+		selectors.Set("labelSelector", labelSelector)
+	}
+	if fieldSelector != "" {
+		selectors.Set("fieldSelector", fieldSelector)
+	}
+	if len(selectors) > 0 {
+		key += "?" + selectors.Encode()
+	}
+	return key
+}
+
+// getStorageClient returns the client of the storage extension for the
+// receiver.
+func getStorageClient(ctx context.Context, host component.Host, storageID config.ComponentID, receiverID config.ComponentID) (storage.Client, error) {
+	extension, ok := host.GetExtensions()[storageID]
+	if !ok {
+		return nil, fmt.Errorf("storage extension %v not found", storageID)
+	}
+	storageExtension, ok := extension.(storage.Extension)
+	if !ok {
+		return nil, fmt.Errorf("extension %v is not a storage extension", storageID)
+	}
+	return storageExtension.GetClient(ctx, component.KindReceiver, receiverID, "")
+}
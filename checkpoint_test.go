@@ -0,0 +1,181 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestCheckpointsPersist(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newMockStorageClient()
+	c := newCheckpoints()
+	c.client = client
+
+	c.set("pods", "5")
+	c.set("events", "")
+	assert.Empty(t, client.get("pods"))
+	require.NoError(t, c.flush(ctx))
+	assert.Equal(t, "5", client.get("pods"))
+	assert.Empty(t, client.get("events"))
+
+	// The checkpoints of a restarted collector are read from storage.
+	restarted := newCheckpoints()
+	restarted.client = client
+	resourceVersion, ok, err := restarted.get(ctx, "pods")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "5", resourceVersion)
+	_, ok, err = restarted.get(ctx, "events")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCheckpointKey(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "//v1, Resource=pods/default", checkpointKey("", podsGVR, "default", "", ""))
+	assert.Equal(t, "//v1, Resource=pods/default?labelSelector=app%3Dweb", checkpointKey("", podsGVR, "default", "app=web", ""))
+	assert.NotEqual(t,
+		checkpointKey("", podsGVR, "", "", "status.phase=Running"),
+		checkpointKey("", podsGVR, "", "", "status.phase=Failed"),
+	)
+}
+
+func TestStartWithoutStorageExtension(t *testing.T) {
+	t.Parallel()
+
+	storageID := config.NewComponentID("file_storage")
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.Storage = &storageID
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, r.Start(context.Background(), componenttest.NewNopHost()), "storage extension file_storage not found")
+}
+
+func TestWatchResumeFromStorage(t *testing.T) {
+	t.Parallel()
+
+	storageID := config.NewComponentID("file_storage")
+	client := newMockStorageClient()
+	host := &mockHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{storageID: &mockStorageExtension{client: client}},
+	}
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+	rCfg.Storage = &storageID
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+	require.NoError(t, kr.Start(context.Background(), host))
+
+	key := checkpointKey(kr.clusters[0].name, podsGVR, "default", "", "")
+	require.NoError(t, client.Set(context.Background(), key, []byte("7")))
+
+	resource := &watchResource{results: make(chan watchResult)}
+	stopperChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchStart: WatchStartResume}, podsGVR, "default", stopperChan, resource)
+	}()
+
+	w := watch.NewFake()
+	resource.results <- watchResult{watcher: w}
+	w.Modify(generateVersionedPod("pod1", "12"))
+	bookmark := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Pod"}}
+	bookmark.SetResourceVersion("15")
+	w.Action(watch.Bookmark, bookmark)
+
+	// Bookmarks persist the resource version.
+	require.Eventually(t, func() bool { return client.get(key) == "15" }, time.Second, time.Millisecond)
+	close(stopperChan)
+	<-done
+
+	assert.Equal(t, []string{"7"}, resource.watches())
+	assert.Equal(t, 0, resource.lists)
+
+	require.NoError(t, kr.Shutdown(context.Background()))
+	assert.True(t, client.closed)
+}
+
+func TestShutdownWaitsForWatches(t *testing.T) {
+	t.Parallel()
+
+	storageID := config.NewComponentID("file_storage")
+	client := newMockStorageClient()
+	host := &mockHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{storageID: &mockStorageExtension{client: client}},
+	}
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+	rCfg.Storage = &storageID
+	consumer := &blockingConsumer{consumed: make(chan struct{}), release: make(chan struct{})}
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+	require.NoError(t, kr.Start(context.Background(), host))
+
+	resource := &watchResource{results: make(chan watchResult)}
+	stopperChan := kr.newStopperChan()
+	kr.goCollect(func() {
+		kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchStart: WatchStartNow}, podsGVR, "default", stopperChan, resource)
+	})
+
+	w := watch.NewFake()
+	resource.results <- watchResult{watcher: w}
+	w.Modify(generateVersionedPod("pod1", "12"))
+	<-consumer.consumed
+
+	// The watch is still sending the event when the receiver shuts down,
+	// it persists its resource version before the client is closed.
+	shutdown := make(chan error)
+	go func() { shutdown <- kr.Shutdown(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	close(consumer.release)
+	require.NoError(t, <-shutdown)
+
+	key := checkpointKey(kr.clusters[0].name, podsGVR, "default", "", "")
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	assert.Equal(t, "12", string(client.data[key]))
+	assert.True(t, client.closed)
+	assert.Zero(t, client.afterClose, "no checkpoints are written after the client is closed")
+}
+
+// blockingConsumer signals every consumed batch of logs and blocks until
+// it is released.
+type blockingConsumer struct {
+	consumed chan struct{}
+	release  chan struct{}
+}
+
+func (c *blockingConsumer) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (c *blockingConsumer) ConsumeLogs(context.Context, plog.Logs) error {
+	c.consumed <- struct{}{}
+	<-c.release
+	return nil
+}
@@ -0,0 +1,71 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// clientSettings identify the receiver in the API clients it creates.
+type clientSettings struct {
+	receiverID config.ComponentID
+	buildInfo  component.BuildInfo
+}
+
+// userAgent returns the default user agent, e.g.
+// otelcol/0.59.0 (linux/amd64) k8sobjects/production.
+func (s clientSettings) userAgent() string {
+	return fmt.Sprintf("%s/%s (%s/%s) %s", s.buildInfo.Command, s.buildInfo.Version, runtime.GOOS, runtime.GOARCH, s.receiverID)
+}
+
+// restConfig returns the rest.Config of the cluster with the client tuning
+// settings applied.
+func (c *ClusterConfig) restConfig(settings clientSettings) (*rest.Config, error) {
+	restConfig, err := c.getRestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig.UserAgent = c.UserAgent
+	if restConfig.UserAgent == "" {
+		restConfig.UserAgent = settings.userAgent()
+	}
+
+	qps, burst := c.QPS, c.Burst
+	if qps == 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst
+	}
+	restConfig.RateLimiter = &throttleRecordingRateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		mutators: []tag.Mutator{
+			tag.Upsert(tagReceiverKey, settings.receiverID.String()),
+			tag.Upsert(tagClusterKey, c.Name),
+		},
+	}
+	return restConfig, nil
+}
+
+// throttleRecordingRateLimiter records how long requests wait on the
+// client-side rate limiter.
+type throttleRecordingRateLimiter struct {
+	flowcontrol.RateLimiter
+	mutators []tag.Mutator
+}
+
+func (l *throttleRecordingRateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.RateLimiter.Wait(ctx)
+	_ = stats.RecordWithTags(ctx, l.mutators, mClientThrottleWait.M(float64(time.Since(start))/float64(time.Millisecond)))
+	return err
+}
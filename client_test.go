@@ -0,0 +1,55 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+)
+
+func TestRestConfig(t *testing.T) {
+	settings := clientSettings{
+		receiverID: config.NewComponentIDWithName(typeStr, "production"),
+		buildInfo: component.BuildInfo{
+			Command: "otelcol",
+			Version: "0.59.0",
+		},
+	}
+	cluster := &ClusterConfig{
+		Name: "production",
+		APIConfig: APIConfig{
+			AuthType:       AuthTypeKubeConfig,
+			KubeConfigPath: filepath.Join("testdata", "kubeconfig.yaml"),
+		},
+	}
+
+	restConfig, err := cluster.restConfig(settings)
+	require.NoError(t, err)
+	assert.Regexp(t, `^otelcol/0\.59\.0 \(\w+/\w+\) k8sobjects/production$`, restConfig.UserAgent)
+	assert.Equal(t, float32(5), restConfig.RateLimiter.QPS())
+
+	cluster.QPS = 50
+	cluster.Burst = 100
+	cluster.UserAgent = "inventory-collector"
+	restConfig, err = cluster.restConfig(settings)
+	require.NoError(t, err)
+	assert.Equal(t, "inventory-collector", restConfig.UserAgent)
+	assert.Equal(t, float32(50), restConfig.RateLimiter.QPS())
+
+	require.NoError(t, view.Register(metricViews()...))
+	defer view.Unregister(metricViews()...)
+	require.NoError(t, restConfig.RateLimiter.Wait(context.Background()))
+
+	rows, err := view.RetrieveData(mClientThrottleWait.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(1), rows[0].Data.(*view.DistributionData).Count)
+	assert.Contains(t, rows[0].Tags, tag.Tag{Key: tagClusterKey, Value: "production"})
+	assert.Contains(t, rows[0].Tags, tag.Tag{Key: tagReceiverKey, Value: "k8sobjects/production"})
+}
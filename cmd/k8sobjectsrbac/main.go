@@ -0,0 +1,165 @@
+// Program k8sobjectsrbac prints the ClusterRole, Roles and bindings the
+// k8sobjects receiver needs to collect the objects of a collector config.
+//
+//	k8sobjectsrbac --config otel.yaml --service-account otel-collector --namespace observability
+//
+// Object references are resolved through API discovery using the receiver
+// settings, or offline from a YAML list of APIResourceList objects given
+// with --resources.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/confmap"
+	"gopkg.in/yaml.v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/harshit-splunk/k8sobjectreceiver"
+)
+
+func main() {
+	configPath := flag.String("config", "", "Path to the collector config.")
+	receiverID := flag.String("receiver", "k8sobjects", "Id of the receiver in the collector config.")
+	clusterName := flag.String("cluster", "", "Name of the cluster when the receiver collects from several clusters.")
+	resourcesPath := flag.String("resources", "", "Path to a YAML list of APIResourceList objects, used instead of API discovery.")
+	name := flag.String("name", "otel-collector-k8sobjects", "Name of the generated roles and bindings.")
+	serviceAccount := flag.String("service-account", "otel-collector", "Service account the collector runs as.")
+	namespace := flag.String("namespace", "default", "Namespace of the service account.")
+	flag.Parse()
+
+	if *configPath == "" {
+		log.Fatal("--config is required")
+	}
+	if err := run(os.Stdout, *configPath, *receiverID, *clusterName, *resourcesPath, *name, *serviceAccount, *namespace); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(out io.Writer, configPath, receiverID, clusterName, resourcesPath, name, serviceAccount, namespace string) error {
+	cfg, err := loadConfig(configPath, receiverID)
+	if err != nil {
+		return err
+	}
+
+	var resources []*metav1.APIResourceList
+	if resourcesPath != "" {
+		data, err := os.ReadFile(resourcesPath)
+		if err != nil {
+			return err
+		}
+		if err = sigsyaml.Unmarshal(data, &resources); err != nil {
+			return fmt.Errorf("invalid resources file: %w", err)
+		}
+	}
+
+	rules, err := cfg.RBACRules(clusterName, resources)
+	if err != nil {
+		return err
+	}
+
+	subjects := []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccount,
+			Namespace: namespace,
+		},
+	}
+	var objects []runtime.Object
+	if len(rules.ClusterRules) > 0 {
+		objects = append(objects,
+			&rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Rules:      rules.ClusterRules,
+			},
+			&rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+				Subjects:   subjects,
+			},
+		)
+	}
+
+	namespaces := make([]string, 0, len(rules.NamespaceRules))
+	for ns := range rules.NamespaceRules {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Rules:      rules.NamespaceRules[ns],
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+				Subjects:   subjects,
+			},
+		)
+	}
+
+	for _, obj := range objects {
+		data, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(out, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadConfig reads the receiver config from a collector config file.
+func loadConfig(path, receiverID string) (*k8sobjectreceiver.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	id, err := config.NewComponentIDFromString(receiverID)
+	if err != nil {
+		return nil, err
+	}
+	receivers, err := confmap.NewFromStringMap(raw).Sub("receivers")
+	if err != nil {
+		return nil, err
+	}
+	if !receivers.IsSet(receiverID) {
+		return nil, fmt.Errorf("receiver %v not found in %v", receiverID, path)
+	}
+	conf, err := receivers.Sub(receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := k8sobjectreceiver.NewFactory()
+	cfg := factory.CreateDefaultConfig()
+	cfg.SetIDName(id.Name())
+	if err = config.UnmarshalReceiver(conf, cfg); err != nil {
+		return nil, err
+	}
+	rcfg := cfg.(*k8sobjectreceiver.Config)
+	if err = rcfg.Validate(); err != nil {
+		return nil, err
+	}
+	return rcfg, nil
+}
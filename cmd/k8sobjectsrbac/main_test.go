@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	testdata := filepath.Join("..", "..", "testdata")
+
+	var out bytes.Buffer
+	err := run(&out, filepath.Join(testdata, "rbac_config.yaml"), "k8sobjects", "", filepath.Join(testdata, "api_resources.yaml"), "collector", "otel-collector", "observability")
+	require.NoError(t, err)
+
+	manifest := out.String()
+	assert.Contains(t, manifest, "kind: ClusterRole\n")
+	assert.Contains(t, manifest, "kind: ClusterRoleBinding\n")
+	assert.Contains(t, manifest, "kind: Role\n")
+	assert.Contains(t, manifest, "kind: RoleBinding\n")
+	assert.Contains(t, manifest, "  namespace: monitoring\n")
+	assert.Contains(t, manifest, "- kind: ServiceAccount\n  name: otel-collector\n  namespace: observability\n")
+
+	err = run(&out, filepath.Join(testdata, "rbac_config.yaml"), "k8sobjects/missing", "", "", "collector", "otel-collector", "observability")
+	assert.EqualError(t, err, "receiver k8sobjects/missing not found in "+filepath.Join(testdata, "rbac_config.yaml"))
+}
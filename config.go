@@ -2,15 +2,24 @@ package k8sobjectreceiver
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"go.opentelemetry.io/collector/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type Mode string
@@ -25,57 +34,615 @@ var modeMap = map[Mode]bool{
 	WatchMode: true,
 }
 
+// PullEmit is which objects pull mode sends to the consumer.
+type PullEmit string
+
+const (
+	// PullEmitAll sends every object on every pull.
+	PullEmitAll PullEmit = "all"
+	// PullEmitChanges sends only the objects that were added or modified
+	// since the previous pull, and a deletion record for the objects that
+	// disappeared. The first pull sends every object as added.
+	PullEmitChanges PullEmit = "changes"
+)
+
+var pullEmitMap = map[PullEmit]bool{
+	PullEmitAll:     true,
+	PullEmitChanges: true,
+}
+
+type AuthType string
+
+const (
+	// AuthTypeNone connects to the API server without any credentials.
+	AuthTypeNone AuthType = "none"
+	// AuthTypeServiceAccount uses the service account token mounted in the pod.
+	AuthTypeServiceAccount AuthType = "serviceAccount"
+	// AuthTypeKubeConfig loads credentials from a kubeconfig file.
+	AuthTypeKubeConfig AuthType = "kubeConfig"
+)
+
+var authTypeMap = map[AuthType]bool{
+	AuthTypeNone:           true,
+	AuthTypeServiceAccount: true,
+	AuthTypeKubeConfig:     true,
+}
+
+// APIConfig contains the settings used to connect to the Kubernetes API server.
+type APIConfig struct {
+	// AuthType is how to authenticate to the API server. Defaults to serviceAccount.
+	AuthType AuthType `mapstructure:"auth_type"`
+	// Context is the kubeconfig context to use when AuthType is kubeConfig.
+	// Defaults to the current context of the kubeconfig.
+	Context string `mapstructure:"context"`
+	// KubeConfigPath is the path to the kubeconfig file when AuthType is kubeConfig.
+	// Defaults to the KUBECONFIG environment variable or ~/.kube/config.
+	KubeConfigPath string `mapstructure:"kube_config_path"`
+	// QPS and Burst configure the client-side rate limiter. They default to
+	// 5 and 10, the client-go defaults.
+	QPS   float32 `mapstructure:"qps"`
+	Burst int     `mapstructure:"burst"`
+	// Timeout bounds discovery and list requests. Watches are not bounded.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// UserAgent overrides the default user agent, which contains the
+	// collector build info and the receiver id.
+	UserAgent string `mapstructure:"user_agent"`
+}
+
+func (c APIConfig) Validate() error {
+	if c.QPS < 0 || c.Burst < 0 || c.Timeout < 0 {
+		return fmt.Errorf("qps, burst and timeout cannot be negative")
+	}
+	if _, ok := authTypeMap[c.AuthType]; !ok {
+		return fmt.Errorf("invalid auth_type: %v", c.AuthType)
+	}
+	if c.AuthType != AuthTypeKubeConfig && (c.Context != "" || c.KubeConfigPath != "") {
+		return fmt.Errorf("context and kube_config_path are only supported with auth_type %v", AuthTypeKubeConfig)
+	}
+	return nil
+}
+
+// getRestConfig creates the rest.Config shared by all clients of the receiver.
+func (c APIConfig) getRestConfig() (*rest.Config, error) {
+	switch c.AuthType {
+	case AuthTypeServiceAccount:
+		return rest.InClusterConfig()
+	case AuthTypeKubeConfig:
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = c.KubeConfigPath
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules,
+			&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+		).ClientConfig()
+	case AuthTypeNone:
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("unable to load k8s config, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+		}
+		return &rest.Config{
+			Host:            "https://" + net.JoinHostPort(host, port),
+			TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid auth_type: %v", c.AuthType)
+	}
+}
+
 type K8sObjectsConfig struct {
-	Name          string        `mapstructure:"name"`
-	Namespaces    []string      `mapstructure:"namespaces"`
-	Mode          Mode          `mapstructure:"mode"`
-	LabelSelector string        `mapstructure:"label_selector"`
-	FieldSelector string        `mapstructure:"field_selector"`
-	Interval      time.Duration `mapstructure:"interval"`
-	gvr           *schema.GroupVersionResource
+	// Name references the resource by plural, singular or short name,
+	// optionally qualified with its group, e.g. deployments.apps. It can
+	// also be a glob, e.g. *.cert-manager.io, or a regular expression
+	// prefixed with "regex:" that is matched against the group qualified
+	// names of all resources supporting the mode.
+	Name string `mapstructure:"name"`
+	// Exclude lists patterns of resources that are not collected when Name
+	// is a pattern. Patterns are matched against both the resource name and
+	// its group qualified name.
+	Exclude []string `mapstructure:"exclude"`
+	// Group and Version narrow down the resource referenced by Name or Kind.
+	// Group core selects the core group, e.g. for events, which are also
+	// served by events.k8s.io.
+	Group   string `mapstructure:"group"`
+	Version string `mapstructure:"version"`
+	// Kind references the resource by its kind, e.g. Deployment.
+	Kind       string   `mapstructure:"kind"`
+	Namespaces []string `mapstructure:"namespaces"`
+	// NamespaceSelector collects the object in the namespaces whose labels
+	// match the selector. Namespaces are watched, so collection starts and
+	// stops as namespaces are created, relabeled or deleted.
+	NamespaceSelector string `mapstructure:"namespace_selector"`
+	// ExcludeNamespaces lists namespaces the object is not collected from.
+	ExcludeNamespaces []string `mapstructure:"exclude_namespaces"`
+	Mode              Mode     `mapstructure:"mode"`
+	LabelSelector     string   `mapstructure:"label_selector"`
+	FieldSelector     string   `mapstructure:"field_selector"`
+	// Interval is how often pull mode lists the objects, every hour when
+	// neither it nor Schedule is set.
+	Interval time.Duration `mapstructure:"interval"`
+	// Schedule is a cron expression, e.g. "5 * * * *" for every hour at
+	// minute 5, that pulls at fixed times instead of every Interval. It
+	// uses the local time zone unless prefixed with CRON_TZ=<zone>.
+	Schedule string `mapstructure:"schedule"`
+	// MinInterval and MaxInterval make the interval adaptive: it doubles
+	// up to MaxInterval while the objects do not change between pulls and
+	// is reset to MinInterval when they change. Interval is the initial
+	// interval and defaults to MinInterval.
+	MinInterval time.Duration `mapstructure:"min_interval"`
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+	// Jitter delays every pull by a random duration up to Jitter.
+	Jitter time.Duration `mapstructure:"jitter"`
+	// InitialPull is when the first pull happens: immediate, the default,
+	// staggered or scheduled.
+	InitialPull InitialPull `mapstructure:"initial_pull"`
+	// PullEmit is which objects are sent on every pull: all, the default,
+	// or changes.
+	PullEmit PullEmit `mapstructure:"pull_emit"`
+	// Content is full, the default, to collect the whole objects or
+	// metadata to only collect their metadata.
+	Content Content `mapstructure:"content"`
+	// Format is object, the default, or table to pull the objects as a
+	// server-side table with the columns of kubectl get.
+	Format Format `mapstructure:"format"`
+	// WatchStart is how a watch starts: list, the default, sends the
+	// existing objects as added before watching, now only watches for
+	// changes from now on and resume watches from the checkpoint of the
+	// previous watch. It defaults to resume when storage is configured.
+	WatchStart WatchStart `mapstructure:"watch_start"`
+	// WatchBackend is watch, the default, to watch the resource of the
+	// object directly, or informer to receive the events from an informer
+	// shared with the objects that watch the same resource, namespace and
+	// selectors. Informers always start with a list and only collect the
+	// whole objects. They are opt-in because they cache every object of
+	// the resource in memory, which is costly for large resources such as
+	// events, and cannot resume from checkpoints with watch_start.
+	WatchBackend WatchBackend `mapstructure:"watch_backend"`
+	// Diff attaches the diff from the previous version to the records of
+	// modified objects, as a json_patch or merge_patch, with the changed
+	// fields. The previous versions are kept in memory. With DiffOnly, the
+	// body only holds the diff instead of the object.
+	Diff     DiffFormat `mapstructure:"diff"`
+	DiffOnly bool       `mapstructure:"diff_only"`
+	// EmitBookmarks and EmitErrors send the bookmark and error events of
+	// watch mode as records. Bookmarks otherwise only advance the resource
+	// version that watches resume from, and errors are logged.
+	EmitBookmarks bool `mapstructure:"emit_bookmarks"`
+	EmitErrors    bool `mapstructure:"emit_errors"`
+	// Timeout bounds a pull, including all its pages and the time spent
+	// waiting for a worker. A pull that times out is cancelled and logged.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// SnapshotMarkers sends a record before and after the objects of every
+	// pull, with the number of objects, so that consumers can detect
+	// complete snapshots. The records of a pull share a k8s.snapshot.id.
+	SnapshotMarkers bool `mapstructure:"snapshot_markers"`
+	// PageSize paginates the lists of pull mode, every page is sent to the
+	// consumer as it is received. Lists are not paginated by default.
+	PageSize int64 `mapstructure:"page_size"`
+	// ResourceVersion and ResourceVersionMatch set the semantics of the
+	// lists of pull mode. NotOlderThan with resource version "0", the
+	// default for NotOlderThan, serves lists from the API server watch
+	// cache instead of a quorum read from etcd.
+	ResourceVersion      string                      `mapstructure:"resource_version"`
+	ResourceVersionMatch metav1.ResourceVersionMatch `mapstructure:"resource_version_match"`
+}
+
+// isPattern returns whether the object selects resources by a pattern.
+func (c *K8sObjectsConfig) isPattern() bool {
+	return isPattern(c.Name)
+}
+
+func (c *K8sObjectsConfig) isExcludedNamespace(namespace string) bool {
+	for _, ns := range c.ExcludeNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldSelector returns the field selector of the object, extended to skip
+// the excluded namespaces when collecting from all namespaces.
+func (c *K8sObjectsConfig) fieldSelector() string {
+	selectors := make([]string, 0, len(c.ExcludeNamespaces)+1)
+	if c.FieldSelector != "" {
+		selectors = append(selectors, c.FieldSelector)
+	}
+	for _, ns := range c.ExcludeNamespaces {
+		selectors = append(selectors, "metadata.namespace!="+ns)
+	}
+	return strings.Join(selectors, ",")
+}
+
+// forResource returns the object to collect a resource with. Cluster-scoped
+// resources have no namespace to exclude, and their field selectors reject
+// the namespace field, so their object does not exclude namespaces.
+func (c *K8sObjectsConfig) forResource(res apiResource) *K8sObjectsConfig {
+	if res.resource.Namespaced || len(c.ExcludeNamespaces) == 0 {
+		return c
+	}
+	object := *c
+	object.ExcludeNamespaces = nil
+	return &object
+}
+
+// apiGroup returns the group the resource of the object is narrowed down
+// to, and whether the object sets one. The core group is set as core.
+func (c *K8sObjectsConfig) apiGroup() (string, bool) {
+	if c.Group == coreGroup {
+		return "", true
+	}
+	return c.Group, c.Group != ""
+}
+
+// reference returns how the object refers to its resource, for messages.
+func (c *K8sObjectsConfig) reference() string {
+	if c.Kind != "" {
+		return c.Kind
+	}
+	return c.Name
+}
+
+// ClusterConfig describes a single cluster to collect objects from.
+type ClusterConfig struct {
+	// Name is added to every record as the k8s.cluster.name resource attribute.
+	Name      string `mapstructure:"name"`
+	APIConfig `mapstructure:",squash"`
+	Objects   []*K8sObjectsConfig `mapstructure:"objects"`
+
+	// For mocking purposes only.
+	makeDiscoveryClient    func() (discovery.ServerResourcesInterface, error)
+	makeDynamicClient      func() (dynamic.Interface, error)
+	makeMetadataClient     func() (metadata.Interface, error)
+	makeTableClient        func() (rest.Interface, error)
+	makeAccessReviewClient func() (authorizationv1.SelfSubjectAccessReviewInterface, error)
 }
 
 type Config struct {
 	config.ReceiverSettings `mapstructure:",squash"`
-	Objects                 []*K8sObjectsConfig `mapstructure:"objects"`
+	APIConfig               `mapstructure:",squash"`
+	// ClusterName is the k8s.cluster.name resource attribute for objects
+	// configured at the top level. It is omitted when empty.
+	ClusterName string              `mapstructure:"cluster_name"`
+	Objects     []*K8sObjectsConfig `mapstructure:"objects"`
+	// Clusters configures collection from several clusters. It cannot be
+	// combined with the top level objects.
+	Clusters []*ClusterConfig `mapstructure:"clusters"`
+	// DiscoveryInterval is how often API discovery is repeated to pick up
+	// resources, such as CRDs, that are installed after the receiver starts.
+	DiscoveryInterval time.Duration `mapstructure:"discovery_interval"`
+	// PermissionCheck controls what happens when the resource of an object
+	// does not support the verbs needed by its mode, or the receiver lacks
+	// the permissions to use them: strict stops the receiver, lenient skips
+	// the object and disabled skips the check.
+	PermissionCheck PermissionCheck `mapstructure:"permission_check"`
+	// Workers limits the number of list requests of pull mode in flight
+	// across all objects and clusters. It is unlimited by default.
+	Workers int `mapstructure:"workers"`
+	// Storage is the ID of a storage extension that persists the resource
+	// versions of watches across restarts of the collector. Watches of
+	// objects without watch_start resume from them.
+	Storage *config.ComponentID `mapstructure:"storage"`
 
 	// For mocking purposes only.
-	makeDiscoveryClient func() (discovery.ServerResourcesInterface, error)
-	makeDynamicClient   func() (dynamic.Interface, error)
+	makeDiscoveryClient    func() (discovery.ServerResourcesInterface, error)
+	makeDynamicClient      func() (dynamic.Interface, error)
+	makeMetadataClient     func() (metadata.Interface, error)
+	makeTableClient        func() (rest.Interface, error)
+	makeAccessReviewClient func() (authorizationv1.SelfSubjectAccessReviewInterface, error)
 }
 
 func (c *Config) Validate() error {
+	if len(c.Clusters) > 0 && len(c.Objects) > 0 {
+		return fmt.Errorf("objects and clusters cannot be configured together")
+	}
 
-	validObjects, err := c.getValidObjects()
-	if err != nil {
-		return err
+	if c.DiscoveryInterval <= 0 {
+		return fmt.Errorf("discovery_interval must be positive")
 	}
-	for _, object := range c.Objects {
-		gvr, ok := validObjects[object.Name]
-		if !ok {
-			return fmt.Errorf("resource %v not found", object.Name)
+
+	if _, ok := permissionCheckMap[c.PermissionCheck]; !ok {
+		return fmt.Errorf("invalid permission_check: %v", c.PermissionCheck)
+	}
+
+	if c.Workers < 0 {
+		return fmt.Errorf("workers cannot be negative")
+	}
+
+	clusterNames := make(map[string]bool)
+	for _, cluster := range c.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster name must be set")
+		}
+		if clusterNames[cluster.Name] {
+			return fmt.Errorf("duplicate cluster name: %v", cluster.Name)
 		}
+		clusterNames[cluster.Name] = true
 
+		if cluster.AuthType == "" {
+			cluster.AuthType = AuthTypeKubeConfig
+		}
+	}
+
+	for _, cluster := range c.clusters() {
+		if c.Storage != nil {
+			for _, object := range cluster.Objects {
+				if object.Mode == WatchMode && object.WatchStart == "" && object.WatchBackend != WatchBackendInformer {
+					object.WatchStart = WatchStartResume
+				}
+			}
+		}
+		if err := cluster.validate(); err != nil {
+			if cluster.Name != "" {
+				return fmt.Errorf("cluster %v: %w", cluster.Name, err)
+			}
+			return err
+		}
+	}
+	return c.ReceiverSettings.Validate()
+}
+
+// clusters returns the clusters to collect objects from. The top level
+// settings are returned as a single cluster when no clusters are configured.
+func (c *Config) clusters() []*ClusterConfig {
+	if len(c.Clusters) == 0 {
+		return []*ClusterConfig{
+			{
+				Name:                   c.ClusterName,
+				APIConfig:              c.APIConfig,
+				Objects:                c.Objects,
+				makeDiscoveryClient:    c.makeDiscoveryClient,
+				makeDynamicClient:      c.makeDynamicClient,
+				makeMetadataClient:     c.makeMetadataClient,
+				makeTableClient:        c.makeTableClient,
+				makeAccessReviewClient: c.makeAccessReviewClient,
+			},
+		}
+	}
+
+	for _, cluster := range c.Clusters {
+		if cluster.makeDiscoveryClient == nil {
+			cluster.makeDiscoveryClient = c.makeDiscoveryClient
+		}
+		if cluster.makeDynamicClient == nil {
+			cluster.makeDynamicClient = c.makeDynamicClient
+		}
+		if cluster.makeMetadataClient == nil {
+			cluster.makeMetadataClient = c.makeMetadataClient
+		}
+		if cluster.makeTableClient == nil {
+			cluster.makeTableClient = c.makeTableClient
+		}
+		if cluster.makeAccessReviewClient == nil {
+			cluster.makeAccessReviewClient = c.makeAccessReviewClient
+		}
+	}
+	return c.Clusters
+}
+
+func (c *ClusterConfig) validate() error {
+	if err := c.APIConfig.Validate(); err != nil {
+		return err
+	}
+
+	for _, object := range c.Objects {
 		if object.Mode == "" {
 			object.Mode = PullMode
 		} else if _, ok := modeMap[object.Mode]; !ok {
 			return fmt.Errorf("invalid mode: %v", object.Mode)
 		}
+		if object.Name == "" && object.Kind == "" {
+			return fmt.Errorf("either name or kind must be set")
+		}
+		if object.Name != "" && object.Kind != "" {
+			return fmt.Errorf("name and kind cannot be set together")
+		}
+		if object.PageSize < 0 {
+			return fmt.Errorf("page_size cannot be negative")
+		}
+		if object.ResourceVersion != "" || object.ResourceVersionMatch != "" {
+			if object.Mode != PullMode {
+				return fmt.Errorf("resource_version and resource_version_match are only supported in %v mode", PullMode)
+			}
+			switch object.ResourceVersionMatch {
+			case "":
+			case metav1.ResourceVersionMatchNotOlderThan:
+				if object.ResourceVersion == "" {
+					object.ResourceVersion = "0"
+				}
+			case metav1.ResourceVersionMatchExact:
+				if object.ResourceVersion == "" || object.ResourceVersion == "0" {
+					return fmt.Errorf("resource_version_match %v requires a non zero resource_version", object.ResourceVersionMatch)
+				}
+			default:
+				return fmt.Errorf("invalid resource_version_match: %v", object.ResourceVersionMatch)
+			}
+		}
+		if object.isPattern() {
+			if _, err := compilePattern(object.Name); err != nil {
+				return fmt.Errorf("invalid name pattern %v: %w", object.Name, err)
+			}
+		} else if len(object.Exclude) > 0 {
+			return fmt.Errorf("exclude is only supported when name is a pattern")
+		}
+		if object.NamespaceSelector != "" {
+			if len(object.Namespaces) > 0 {
+				return fmt.Errorf("namespaces and namespace_selector cannot be set together")
+			}
+			if _, err := labels.Parse(object.NamespaceSelector); err != nil {
+				return fmt.Errorf("invalid namespace_selector: %w", err)
+			}
+		}
+		for _, exclude := range object.Exclude {
+			if _, err := compilePattern(exclude); err != nil {
+				return fmt.Errorf("invalid exclude pattern %v: %w", exclude, err)
+			}
+		}
+		if err := object.validateSchedule(); err != nil {
+			return err
+		}
+		if object.PullEmit != "" {
+			if object.Mode != PullMode {
+				return fmt.Errorf("pull_emit is only supported in %v mode", PullMode)
+			}
+			if _, ok := pullEmitMap[object.PullEmit]; !ok {
+				return fmt.Errorf("invalid pull_emit: %v", object.PullEmit)
+			}
+		} else if object.Mode == PullMode {
+			object.PullEmit = PullEmitAll
+		}
+		if object.Content == "" {
+			object.Content = ContentFull
+		} else if _, ok := contentMap[object.Content]; !ok {
+			return fmt.Errorf("invalid content: %v", object.Content)
+		}
+		if object.Format != "" {
+			if object.Mode != PullMode {
+				return fmt.Errorf("format is only supported in %v mode", PullMode)
+			}
+			if _, ok := formatMap[object.Format]; !ok {
+				return fmt.Errorf("invalid format: %v", object.Format)
+			}
+		} else if object.Mode == PullMode {
+			object.Format = FormatObject
+		}
+		if object.SnapshotMarkers && object.Mode != PullMode {
+			return fmt.Errorf("snapshot_markers is only supported in %v mode", PullMode)
+		}
+		if object.WatchStart != "" {
+			if object.Mode != WatchMode {
+				return fmt.Errorf("watch_start is only supported in %v mode", WatchMode)
+			}
+			if _, ok := watchStartMap[object.WatchStart]; !ok {
+				return fmt.Errorf("invalid watch_start: %v", object.WatchStart)
+			}
+		} else if object.Mode == WatchMode {
+			object.WatchStart = WatchStartList
+		}
+		if (object.EmitBookmarks || object.EmitErrors) && object.Mode != WatchMode {
+			return fmt.Errorf("emit_bookmarks and emit_errors are only supported in %v mode", WatchMode)
+		}
+		if err := object.validateWatchBackend(); err != nil {
+			return err
+		}
+		if object.Diff != "" {
+			if object.Mode != WatchMode {
+				return fmt.Errorf("diff is only supported in %v mode", WatchMode)
+			}
+			if _, ok := diffFormatMap[object.Diff]; !ok {
+				return fmt.Errorf("invalid diff: %v", object.Diff)
+			}
+		} else if object.DiffOnly {
+			return fmt.Errorf("diff_only requires diff")
+		}
+		if object.Timeout != 0 {
+			if object.Mode != PullMode {
+				return fmt.Errorf("timeout is only supported in %v mode", PullMode)
+			}
+			if object.Timeout < 0 {
+				return fmt.Errorf("timeout cannot be negative")
+			}
+		}
+		if object.Format == FormatTable && object.Content == ContentMetadata {
+			return fmt.Errorf("format %v already only collects the metadata, content cannot be %v", FormatTable, ContentMetadata)
+		}
+	}
+	return nil
+}
 
-		object.gvr = gvr
+func (c *K8sObjectsConfig) validateWatchBackend() error {
+	if c.WatchBackend == "" {
+		if c.Mode == WatchMode {
+			c.WatchBackend = WatchBackendWatch
+		}
+		return nil
 	}
-	return c.ReceiverSettings.Validate()
+	if c.Mode != WatchMode {
+		return fmt.Errorf("watch_backend is only supported in %v mode", WatchMode)
+	}
+	if _, ok := watchBackendMap[c.WatchBackend]; !ok {
+		return fmt.Errorf("invalid watch_backend: %v", c.WatchBackend)
+	}
+	if c.WatchBackend != WatchBackendInformer {
+		return nil
+	}
+	if c.Content != ContentFull {
+		return fmt.Errorf("watch_backend %v only supports content %v", WatchBackendInformer, ContentFull)
+	}
+	if c.WatchStart != WatchStartList {
+		return fmt.Errorf("watch_backend %v only supports watch_start %v", WatchBackendInformer, WatchStartList)
+	}
+	if c.EmitBookmarks || c.EmitErrors {
+		return fmt.Errorf("watch_backend %v does not support emit_bookmarks and emit_errors", WatchBackendInformer)
+	}
+	return nil
 }
 
-func (c *Config) getDiscoveryClient() (discovery.ServerResourcesInterface, error) {
+func (c *K8sObjectsConfig) validateSchedule() error {
+	if c.Schedule != "" || c.Jitter != 0 || c.InitialPull != "" || c.MinInterval != 0 || c.MaxInterval != 0 {
+		if c.Mode != PullMode {
+			return fmt.Errorf("schedule, jitter, initial_pull, min_interval and max_interval are only supported in %v mode", PullMode)
+		}
+	}
+	if c.Interval < 0 || c.Jitter < 0 || c.MinInterval < 0 || c.MaxInterval < 0 {
+		return fmt.Errorf("interval, jitter, min_interval and max_interval cannot be negative")
+	}
+	if c.InitialPull == "" {
+		if c.Mode == PullMode {
+			c.InitialPull = InitialPullImmediate
+		}
+	} else if _, ok := initialPullMap[c.InitialPull]; !ok {
+		return fmt.Errorf("invalid initial_pull: %v", c.InitialPull)
+	}
+
+	if c.Schedule != "" {
+		if c.Interval != 0 {
+			return fmt.Errorf("interval and schedule cannot be set together")
+		}
+		if _, err := cron.ParseStandard(c.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule %v: %w", c.Schedule, err)
+		}
+		if c.InitialPull == InitialPullStaggered {
+			return fmt.Errorf("initial_pull %v is not supported with schedule, use jitter instead", InitialPullStaggered)
+		}
+		if c.MinInterval != 0 || c.MaxInterval != 0 {
+			return fmt.Errorf("min_interval and max_interval cannot be set with schedule")
+		}
+		return nil
+	}
+	if c.MinInterval != 0 || c.MaxInterval != 0 {
+		if c.MinInterval == 0 || c.MaxInterval == 0 {
+			return fmt.Errorf("min_interval and max_interval must be set together")
+		}
+		if c.MinInterval >= c.MaxInterval {
+			return fmt.Errorf("min_interval must be less than max_interval")
+		}
+		if c.Interval == 0 {
+			c.Interval = c.MinInterval
+		} else if c.Interval < c.MinInterval || c.Interval > c.MaxInterval {
+			return fmt.Errorf("interval must be between min_interval and max_interval")
+		}
+		if c.Jitter > 0 && c.Jitter >= c.MinInterval {
+			return fmt.Errorf("jitter must be less than min_interval")
+		}
+	}
+	if c.Interval == 0 && c.Mode == PullMode {
+		c.Interval = defaultPullInterval
+	}
+	if c.Jitter > 0 && c.Jitter >= c.Interval {
+		return fmt.Errorf("jitter must be less than interval")
+	}
+	return nil
+}
+
+func (c *ClusterConfig) getDiscoveryClient(settings clientSettings) (discovery.ServerResourcesInterface, error) {
 	if c.makeDiscoveryClient != nil {
 		return c.makeDiscoveryClient()
 	}
 
-	config, err := rest.InClusterConfig()
+	config, err := c.restConfig(settings)
 	if err != nil {
 		return nil, err
 	}
+	config.Timeout = c.Timeout
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -85,44 +652,103 @@ func (c *Config) getDiscoveryClient() (discovery.ServerResourcesInterface, error
 	return client.Discovery(), nil
 }
 
-func (c *Config) getDynamicClient() (dynamic.Interface, error) {
+func (c *ClusterConfig) getDynamicClient(settings clientSettings) (dynamic.Interface, error) {
 	if c.makeDynamicClient != nil {
 		return c.makeDynamicClient()
 	}
-	config, err := rest.InClusterConfig()
-
+	// The timeout is applied to list requests only, setting it on the
+	// rest.Config would also end watches.
+	config, err := c.restConfig(settings)
 	if err != nil {
 		return nil, err
 	}
 	return dynamic.NewForConfig(config)
 }
 
-func (c *Config) getValidObjects() (map[string]*schema.GroupVersionResource, error) {
-	dc, err := c.getDiscoveryClient()
+func (c *ClusterConfig) getMetadataClient(settings clientSettings) (metadata.Interface, error) {
+	if c.makeMetadataClient != nil {
+		return c.makeMetadataClient()
+	}
+	config, err := c.restConfig(settings)
 	if err != nil {
 		return nil, err
 	}
+	return metadata.NewForConfig(config)
+}
 
-	res, err := dc.ServerPreferredResources()
+func (c *ClusterConfig) getTableClient(settings clientSettings) (rest.Interface, error) {
+	if c.makeTableClient != nil {
+		return c.makeTableClient()
+	}
+	config, err := c.restConfig(settings)
 	if err != nil {
 		return nil, err
 	}
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	return rest.UnversionedRESTClientFor(config)
+}
 
-	validObjects := make(map[string]*schema.GroupVersionResource)
+// usesTables returns whether any object is pulled as a table.
+func (c *ClusterConfig) usesTables() bool {
+	for _, object := range c.Objects {
+		if object.Format == FormatTable {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, group := range res {
-		split := strings.Split(group.GroupVersion, "/")
-		if len(split) == 1 && group.GroupVersion == "v1" {
-			split = []string{"", "v1"}
+// usesMetadata returns whether any object only collects metadata.
+func (c *ClusterConfig) usesMetadata() bool {
+	for _, object := range c.Objects {
+		if object.Content == ContentMetadata {
+			return true
 		}
-		for _, resource := range group.APIResources {
-			validObjects[resource.Name] = &schema.GroupVersionResource{
-				Group:    split[0],
-				Version:  split[1],
-				Resource: resource.Name,
-			}
+	}
+	return false
+}
+
+func (c *ClusterConfig) getAccessReviewClient(settings clientSettings) (authorizationv1.SelfSubjectAccessReviewInterface, error) {
+	if c.makeAccessReviewClient != nil {
+		return c.makeAccessReviewClient()
+	}
+	config, err := c.restConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = c.Timeout
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.AuthorizationV1().SelfSubjectAccessReviews(), nil
+}
+
+// getValidObjects discovers the resources of the cluster. Groups that fail
+// discovery, e.g. because an aggregated API service is unavailable, are
+// recorded in the result instead of failing the whole discovery.
+func (c *ClusterConfig) getValidObjects(settings clientSettings) (*discoveredResources, error) {
+	dc, err := c.getDiscoveryClient(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var failedGroups map[schema.GroupVersion]error
+	res, err := dc.ServerPreferredResources()
+	if err != nil {
+		groupErr, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			return nil, err
 		}
+		failedGroups = groupErr.Groups
+	}
 
+	resources, err := newAPIResources(res)
+	if err != nil {
+		return nil, err
 	}
-	return validObjects, nil
+	return &discoveredResources{
+		resources:    resources,
+		failedGroups: failedGroups,
+	}, nil
 }
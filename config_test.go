@@ -10,6 +10,7 @@ import (
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/service/servicetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -28,10 +29,9 @@ func TestLoadConfig(t *testing.T) {
 
 	r1 := cfg.Receivers[config.NewComponentID(typeStr)].(*Config)
 
+	// Validation does not need access to the cluster.
 	err = r1.Validate()
-	require.Error(t, err)
-
-	r1.makeDiscoveryClient = getMockDiscoveryClient
+	require.NoError(t, err)
 
 	expected := []*K8sObjectsConfig{
 		{
@@ -40,11 +40,46 @@ func TestLoadConfig(t *testing.T) {
 			Interval:      time.Second * 30,
 			FieldSelector: "status.phase=Running",
 			LabelSelector: "environment in (production),tier in (frontend)",
+			InitialPull:   InitialPullImmediate,
+			PullEmit:      PullEmitAll,
+			Format:        FormatObject,
+			Content:       ContentFull,
+		},
+		{
+			Name:         "events",
+			Group:        "core",
+			Mode:         WatchMode,
+			Namespaces:   []string{"default"},
+			Content:      ContentFull,
+			WatchStart:   WatchStartList,
+			WatchBackend: WatchBackendInformer,
+			Diff:         DiffMergePatch,
+		},
+		{
+			Name:        "deployments",
+			Mode:        PullMode,
+			Schedule:    "5 * * * *",
+			Jitter:      time.Second * 30,
+			InitialPull: InitialPullScheduled,
+			PullEmit:    PullEmitChanges,
+			Format:      FormatObject,
+			Content:     ContentFull,
+		},
+		{
+			Name:         "configmaps",
+			Mode:         WatchMode,
+			Content:      ContentMetadata,
+			WatchStart:   WatchStartNow,
+			WatchBackend: WatchBackendWatch,
 		},
 		{
-			Name:       "events",
-			Mode:       WatchMode,
-			Namespaces: []string{"default"},
+			Name:        "nodes",
+			Mode:        PullMode,
+			Interval:    time.Minute,
+			InitialPull: InitialPullImmediate,
+			PullEmit:    PullEmitAll,
+			Content:     ContentFull,
+			Format:      FormatTable,
 		},
 	}
 	assert.EqualValues(t, expected, r1.Objects)
@@ -66,11 +101,318 @@ func TestValidConfigs(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, cfg)
 
-	invalid_resource_config := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_resource")].(*Config)
+	nameAndKindConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "name_and_kind")].(*Config)
+	assert.ErrorContains(t, nameAndKindConfig.Validate(), "name and kind cannot be set together")
+
+	missingNameConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "missing_name_and_kind")].(*Config)
+	assert.ErrorContains(t, missingNameConfig.Validate(), "either name or kind must be set")
+
+	invalidPatternConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_pattern")].(*Config)
+	assert.ErrorContains(t, invalidPatternConfig.Validate(), "invalid name pattern [pods")
+
+	excludeConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "exclude_without_pattern")].(*Config)
+	assert.ErrorContains(t, excludeConfig.Validate(), "exclude is only supported when name is a pattern")
+
+	namespacesConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "namespaces_and_selector")].(*Config)
+	assert.ErrorContains(t, namespacesConfig.Validate(), "namespaces and namespace_selector cannot be set together")
+
+	namespaceSelectorConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_namespace_selector")].(*Config)
+	assert.ErrorContains(t, namespaceSelectorConfig.Validate(), "invalid namespace_selector")
+
+	invalidPermissionCheckConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_permission_check")].(*Config)
+	assert.ErrorContains(t, invalidPermissionCheckConfig.Validate(), "invalid permission_check: sometimes")
+
+	pageSizeConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "negative_page_size")].(*Config)
+	assert.ErrorContains(t, pageSizeConfig.Validate(), "page_size cannot be negative")
+
+	resourceVersionMatchConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_resource_version_match")].(*Config)
+	assert.ErrorContains(t, resourceVersionMatchConfig.Validate(), "invalid resource_version_match: Latest")
+
+	exactConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "exact_without_resource_version")].(*Config)
+	assert.ErrorContains(t, exactConfig.Validate(), "resource_version_match Exact requires a non zero resource_version")
+
+	watchResourceVersionConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "resource_version_in_watch_mode")].(*Config)
+	assert.ErrorContains(t, watchResourceVersionConfig.Validate(), "only supported in pull mode")
+
+	scheduleConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_schedule")].(*Config)
+	assert.ErrorContains(t, scheduleConfig.Validate(), "invalid schedule 5 * *")
+
+	scheduleIntervalConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "schedule_with_interval")].(*Config)
+	assert.ErrorContains(t, scheduleIntervalConfig.Validate(), "interval and schedule cannot be set together")
+
+	jitterConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "jitter_exceeds_interval")].(*Config)
+	assert.ErrorContains(t, jitterConfig.Validate(), "jitter must be less than interval")
+
+	initialPullConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_initial_pull")].(*Config)
+	assert.ErrorContains(t, initialPullConfig.Validate(), "invalid initial_pull: later")
+
+	staggeredScheduleConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "staggered_schedule")].(*Config)
+	assert.ErrorContains(t, staggeredScheduleConfig.Validate(), "initial_pull staggered is not supported with schedule")
+
+	pullEmitConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_pull_emit")].(*Config)
+	assert.ErrorContains(t, pullEmitConfig.Validate(), "invalid pull_emit: some")
+
+	watchPullEmitConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "pull_emit_in_watch_mode")].(*Config)
+	assert.ErrorContains(t, watchPullEmitConfig.Validate(), "pull_emit is only supported in pull mode")
+
+	minIntervalConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "min_interval_without_max_interval")].(*Config)
+	assert.ErrorContains(t, minIntervalConfig.Validate(), "min_interval and max_interval must be set together")
+
+	adaptiveRangeConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "min_interval_exceeds_max_interval")].(*Config)
+	assert.ErrorContains(t, adaptiveRangeConfig.Validate(), "min_interval must be less than max_interval")
+
+	adaptiveIntervalConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "interval_outside_adaptive_range")].(*Config)
+	assert.ErrorContains(t, adaptiveIntervalConfig.Validate(), "interval must be between min_interval and max_interval")
+
+	contentConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_content")].(*Config)
+	assert.ErrorContains(t, contentConfig.Validate(), "invalid content: spec")
+
+	formatConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_format")].(*Config)
+	assert.ErrorContains(t, formatConfig.Validate(), "invalid format: yaml")
+
+	watchFormatConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "table_in_watch_mode")].(*Config)
+	assert.ErrorContains(t, watchFormatConfig.Validate(), "format is only supported in pull mode")
+
+	tableMetadataConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "table_with_metadata_content")].(*Config)
+	assert.ErrorContains(t, tableMetadataConfig.Validate(), "format table already only collects the metadata")
+
+	snapshotMarkersConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "snapshot_markers_in_watch_mode")].(*Config)
+	assert.ErrorContains(t, snapshotMarkersConfig.Validate(), "snapshot_markers is only supported in pull mode")
+
+	workersConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "negative_workers")].(*Config)
+	assert.ErrorContains(t, workersConfig.Validate(), "workers cannot be negative")
+
+	watchTimeoutConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "timeout_in_watch_mode")].(*Config)
+	assert.ErrorContains(t, watchTimeoutConfig.Validate(), "timeout is only supported in pull mode")
+
+	watchStartConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_watch_start")].(*Config)
+	assert.ErrorContains(t, watchStartConfig.Validate(), "invalid watch_start: later")
+
+	pullWatchStartConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "watch_start_in_pull_mode")].(*Config)
+	assert.ErrorContains(t, pullWatchStartConfig.Validate(), "watch_start is only supported in watch mode")
+
+	watchBackendConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_watch_backend")].(*Config)
+	assert.ErrorContains(t, watchBackendConfig.Validate(), "invalid watch_backend: cache")
+
+	informerMetadataConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "informer_with_metadata")].(*Config)
+	assert.ErrorContains(t, informerMetadataConfig.Validate(), "watch_backend informer only supports content full")
+
+	informerWatchStartConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "informer_with_watch_start_now")].(*Config)
+	assert.ErrorContains(t, informerWatchStartConfig.Validate(), "watch_backend informer only supports watch_start list")
+
+	diffConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_diff")].(*Config)
+	assert.ErrorContains(t, diffConfig.Validate(), "invalid diff: unified")
+
+	pullDiffConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "diff_in_pull_mode")].(*Config)
+	assert.ErrorContains(t, pullDiffConfig.Validate(), "diff is only supported in watch mode")
+
+	diffOnlyConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "diff_only_without_diff")].(*Config)
+	assert.ErrorContains(t, diffOnlyConfig.Validate(), "diff_only requires diff")
+
+	emitErrorsConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "emit_errors_in_pull_mode")].(*Config)
+	assert.ErrorContains(t, emitErrorsConfig.Validate(), "emit_bookmarks and emit_errors are only supported in watch mode")
+
+	invalidDiscoveryIntervalConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_discovery_interval")].(*Config)
+	assert.ErrorContains(t, invalidDiscoveryIntervalConfig.Validate(), "discovery_interval must be positive")
+
+}
+
+func TestInvalidAPIConfigs(t *testing.T) {
+	t.Parallel()
+	factories, err := componenttest.NopFactories()
+	require.NoError(t, err)
+
+	factory := NewFactory()
+	factories.Receivers[config.Type(typeStr)] = factory
+	cfg, err := servicetest.LoadConfig(filepath.Join("testdata", "invalid_config.yaml"), factories)
+
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	invalidAuthTypeConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "invalid_auth_type")].(*Config)
+	assert.ErrorContains(t, invalidAuthTypeConfig.Validate(), "invalid auth_type: certificate")
+
+	negativeQPSConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "negative_qps")].(*Config)
+	assert.ErrorContains(t, negativeQPSConfig.Validate(), "qps, burst and timeout cannot be negative")
+
+	contextConfig := cfg.Receivers[config.NewComponentIDWithName(typeStr, "context_without_kubeconfig")].(*Config)
+	assert.ErrorContains(t, contextConfig.Validate(), "only supported with auth_type kubeConfig")
+}
+
+func TestGetRestConfig(t *testing.T) {
+	kubeConfigPath := filepath.Join("testdata", "kubeconfig.yaml")
+
+	restConfig, err := APIConfig{
+		AuthType:       AuthTypeKubeConfig,
+		KubeConfigPath: kubeConfigPath,
+	}.getRestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://production.example.com:6443", restConfig.Host)
+	assert.Equal(t, "fake-token", restConfig.BearerToken)
+
+	restConfig, err = APIConfig{
+		AuthType:       AuthTypeKubeConfig,
+		KubeConfigPath: kubeConfigPath,
+		Context:        "staging",
+	}.getRestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com:6443", restConfig.Host)
+
+	_, err = APIConfig{
+		AuthType:       AuthTypeKubeConfig,
+		KubeConfigPath: kubeConfigPath,
+		Context:        "missing",
+	}.getRestConfig()
+	assert.Error(t, err)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	restConfig, err = APIConfig{AuthType: AuthTypeNone}.getRestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://10.0.0.1:443", restConfig.Host)
+	assert.True(t, restConfig.Insecure)
+	assert.Empty(t, restConfig.BearerToken)
+}
+
+func TestLoadMultiClusterConfig(t *testing.T) {
+	t.Parallel()
+	factories, err := componenttest.NopFactories()
+	require.NoError(t, err)
+
+	factory := NewFactory()
+	factories.Receivers[config.Type(typeStr)] = factory
+	cfg, err := servicetest.LoadConfig(filepath.Join("testdata", "multi_cluster_config.yaml"), factories)
+
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	r1 := cfg.Receivers[config.NewComponentID(typeStr)].(*Config)
+	require.NoError(t, r1.Validate())
+
+	expected := []*ClusterConfig{
+		{
+			Name: "production",
+			APIConfig: APIConfig{
+				AuthType:       AuthTypeKubeConfig,
+				KubeConfigPath: "/etc/kube/config",
+				Context:        "production",
+			},
+			Objects: []*K8sObjectsConfig{
+				{
+					Name:         "events",
+					Group:        "core",
+					Mode:         WatchMode,
+					Content:      ContentFull,
+					WatchStart:   WatchStartList,
+					WatchBackend: WatchBackendWatch,
+				},
+			},
+		},
+		{
+			Name: "staging",
+			APIConfig: APIConfig{
+				AuthType:       AuthTypeKubeConfig,
+				KubeConfigPath: "/etc/kube/config",
+				Context:        "staging",
+			},
+			Objects: []*K8sObjectsConfig{
+				{
+					Name:        "pods",
+					Mode:        PullMode,
+					Interval:    time.Minute,
+					InitialPull: InitialPullImmediate,
+					PullEmit:    PullEmitAll,
+					Format:      FormatObject,
+					Content:     ContentFull,
+				},
+			},
+		},
+	}
+	for i, cluster := range r1.clusters() {
+		assert.EqualValues(t, expected[i], cluster)
+	}
+}
+
+func TestInvalidClusterConfigs(t *testing.T) {
+	t.Parallel()
+	factories, err := componenttest.NopFactories()
+	require.NoError(t, err)
+
+	factory := NewFactory()
+	factories.Receivers[config.Type(typeStr)] = factory
+	cfg, err := servicetest.LoadConfig(filepath.Join("testdata", "invalid_config.yaml"), factories)
+
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	tests := map[string]string{
+		"objects_and_clusters":   "objects and clusters cannot be configured together",
+		"missing_cluster_name":   "cluster name must be set",
+		"duplicate_cluster_name": "duplicate cluster name: production",
+	}
+	for name, expectedErr := range tests {
+		rCfg := cfg.Receivers[config.NewComponentIDWithName(typeStr, name)].(*Config)
+		assert.ErrorContains(t, rCfg.Validate(), expectedErr, name)
+	}
+}
+
+func TestFieldSelector(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", (&K8sObjectsConfig{}).fieldSelector())
+	assert.Equal(t, "status.phase=Running", (&K8sObjectsConfig{FieldSelector: "status.phase=Running"}).fieldSelector())
+	assert.Equal(t, "status.phase=Running,metadata.namespace!=kube-system,metadata.namespace!=kube-public", (&K8sObjectsConfig{
+		FieldSelector:     "status.phase=Running",
+		ExcludeNamespaces: []string{"kube-system", "kube-public"},
+	}).fieldSelector())
+
+	// Cluster-scoped resources are not filtered by namespace.
+	object := &K8sObjectsConfig{Name: "*", ExcludeNamespaces: []string{"kube-system"}}
+	nodes := apiResource{resource: metav1.APIResource{Name: "nodes"}}
+	pods := apiResource{resource: metav1.APIResource{Name: "pods", Namespaced: true}}
+	assert.Equal(t, "", object.forResource(nodes).fieldSelector())
+	assert.Equal(t, "metadata.namespace!=kube-system", object.forResource(pods).fieldSelector())
+	assert.Equal(t, []string{"kube-system"}, object.ExcludeNamespaces)
+}
+
+func TestStorageDefaultsWatchStart(t *testing.T) {
+	t.Parallel()
 
-	invalid_resource_config.makeDiscoveryClient = getMockDiscoveryClient
+	storageID := config.NewComponentID("file_storage")
+	cfg := createDefaultConfig().(*Config)
+	cfg.Storage = &storageID
+	cfg.Objects = []*K8sObjectsConfig{
+		{Name: "events", Mode: WatchMode},
+		{Name: "pods", Mode: WatchMode, WatchStart: WatchStartNow},
+		{Name: "nodes", Mode: PullMode},
+		{Name: "configmaps", Mode: WatchMode, WatchBackend: WatchBackendInformer},
+	}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, WatchStartResume, cfg.Objects[0].WatchStart)
+	assert.Equal(t, WatchStartNow, cfg.Objects[1].WatchStart)
+	assert.Empty(t, cfg.Objects[2].WatchStart)
+	assert.Equal(t, WatchStartList, cfg.Objects[3].WatchStart)
+}
+
+func TestDefaultPullInterval(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Objects = []*K8sObjectsConfig{
+		{Name: "nodes", Mode: PullMode},
+		{Name: "pods", Mode: PullMode, Schedule: "5 * * * *"},
+		{Name: "events", Mode: WatchMode},
+	}
+	require.NoError(t, cfg.Validate())
 
-	err = invalid_resource_config.Validate()
-	assert.ErrorContains(t, err, "resource fake_resource not found")
+	assert.Equal(t, defaultPullInterval, cfg.Objects[0].Interval)
+	assert.Zero(t, cfg.Objects[1].Interval)
+	assert.Zero(t, cfg.Objects[2].Interval)
 
+	// The next pull of the default interval is after the current one.
+	schedule := newPullSchedule(cfg.Objects[0], "nodes")
+	now := time.Now()
+	schedule.first(now)
+	assert.Equal(t, now.Add(defaultPullInterval), schedule.after(now))
 }
@@ -0,0 +1,222 @@
+package k8sobjectreceiver
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// DiffFormat is the format of the diffs attached to the records of
+// modified objects in watch mode.
+type DiffFormat string
+
+const (
+	// DiffJSONPatch is a JSON Patch, RFC 6902, from the previous version.
+	DiffJSONPatch DiffFormat = "json_patch"
+	// DiffMergePatch is a JSON Merge Patch, RFC 7386, from the previous
+	// version.
+	DiffMergePatch DiffFormat = "merge_patch"
+)
+
+var diffFormatMap = map[DiffFormat]bool{
+	DiffJSONPatch:  true,
+	DiffMergePatch: true,
+}
+
+// ignoredDiffPaths change with every update, or with every apply, without
+// being a change of the object.
+var ignoredDiffPaths = map[string]bool{
+	"/metadata/resourceVersion": true,
+	"/metadata/managedFields":   true,
+}
+
+// patchOperation is an operation of a JSON Patch.
+type patchOperation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// MarshalJSON keeps null values, only remove operations have no value.
+func (op patchOperation) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(map[string]interface{}{"op": op.Op, "path": op.Path})
+	}
+	return json.Marshal(map[string]interface{}{"op": op.Op, "path": op.Path, "value": op.Value})
+}
+
+// objectDiff is the difference between two versions of an object.
+type objectDiff struct {
+	// patch is the diff in its format, decoded from JSON.
+	patch interface{}
+	// changedFields are the JSON Pointers of the fields that were added,
+	// removed or replaced, in order.
+	changedFields []string
+}
+
+// newObjectDiff returns the diff from the previous to the current version
+// of an object. Lists are replaced as a whole, and the resource version and
+// managed fields are not part of the diff.
+func newObjectDiff(format DiffFormat, previous, current *unstructured.Unstructured) (*objectDiff, error) {
+	ops := diffValues("", previous.Object, current.Object, nil)
+	diff := &objectDiff{changedFields: make([]string, 0, len(ops))}
+	for _, op := range ops {
+		diff.changedFields = append(diff.changedFields, op.Path)
+	}
+
+	var patch interface{} = ops
+	if format == DiffMergePatch {
+		patch = mergePatch(ops)
+	}
+	// The patch is decoded from JSON so that it only holds the types of
+	// log record values.
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &diff.patch); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// diffValues appends the operations that turn previous into current at path.
+func diffValues(path string, previous, current interface{}, ops []patchOperation) []patchOperation {
+	if ignoredDiffPaths[path] || reflect.DeepEqual(previous, current) {
+		return ops
+	}
+	previousMap, ok := previous.(map[string]interface{})
+	currentMap, isMap := current.(map[string]interface{})
+	if !ok || !isMap {
+		return append(ops, patchOperation{Op: "replace", Path: path, Value: current})
+	}
+
+	keys := make([]string, 0, len(previousMap)+len(currentMap))
+	for key := range previousMap {
+		keys = append(keys, key)
+	}
+	for key := range currentMap {
+		if _, ok := previousMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := path + "/" + escapePointer(key)
+		previousValue, inPrevious := previousMap[key]
+		currentValue, inCurrent := currentMap[key]
+		switch {
+		case ignoredDiffPaths[keyPath]:
+		case !inCurrent:
+			ops = append(ops, patchOperation{Op: "remove", Path: keyPath})
+		case !inPrevious:
+			ops = append(ops, patchOperation{Op: "add", Path: keyPath, Value: currentValue})
+		default:
+			ops = diffValues(keyPath, previousValue, currentValue, ops)
+		}
+	}
+	return ops
+}
+
+// mergePatch converts the operations of a JSON Patch to a merge patch. The
+// operations only traverse objects, lists are replaced as a whole.
+func mergePatch(ops []patchOperation) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for _, op := range ops {
+		keys := strings.Split(op.Path, "/")[1:]
+		parent := patch
+		for _, key := range keys[:len(keys)-1] {
+			key = unescapePointer(key)
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		// Removed fields are set to null.
+		parent[unescapePointer(keys[len(keys)-1])] = op.Value
+	}
+	return patch
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func unescapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
+}
+
+// objectVersions keeps the last version of every object of a watch by uid,
+// to diff the versions of modified objects.
+type objectVersions struct {
+	objects map[types.UID]*unstructured.Unstructured
+}
+
+func newObjectVersions() *objectVersions {
+	return &objectVersions{objects: make(map[types.UID]*unstructured.Unstructured)}
+}
+
+// update records the object of an event and returns its previous version,
+// nil when it is not known.
+func (v *objectVersions) update(event watch.Event, object *unstructured.Unstructured) *unstructured.Unstructured {
+	previous := v.objects[object.GetUID()]
+	if event.Type == watch.Deleted {
+		delete(v.objects, object.GetUID())
+	} else {
+		v.objects[object.GetUID()] = object
+	}
+	return previous
+}
+
+// reset replaces the versions with the objects of a list.
+func (v *objectVersions) reset(list *unstructured.UnstructuredList) {
+	v.objects = make(map[types.UID]*unstructured.Unstructured, len(list.Items))
+	for i := range list.Items {
+		v.objects[list.Items[i].GetUID()] = &list.Items[i]
+	}
+}
+
+// modifiedEventToLogData converts an event, with the diff from the previous
+// version when the object was modified and diffs are enabled. Without
+// diff_only, the patch is added as the k8s.object.diff attribute in JSON,
+// with diff_only it replaces the object in the body.
+func modifiedEventToLogData(config *K8sObjectsConfig, event watch.Event, previous *unstructured.Unstructured, clusterName string) (plog.Logs, error) {
+	out := watchEventToLogData(event, clusterName)
+	if config.Diff == "" || event.Type != watch.Modified || previous == nil {
+		return out, nil
+	}
+	diff, err := newObjectDiff(config.Diff, previous, event.Object.(*unstructured.Unstructured))
+	if err != nil {
+		return out, err
+	}
+
+	record := out.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	attrs := record.Attributes()
+	attrs.UpsertString("k8s.object.diff_format", string(config.Diff))
+	changedFields := attrs.UpsertEmptySlice("k8s.object.changed_fields")
+	changedFields.EnsureCapacity(len(diff.changedFields))
+	for _, field := range diff.changedFields {
+		changedFields.AppendEmpty().SetStringVal(field)
+	}
+	if !config.DiffOnly {
+		data, err := json.Marshal(diff.patch)
+		if err != nil {
+			return out, err
+		}
+		attrs.UpsertString("k8s.object.diff", string(data))
+		return out, nil
+	}
+	toMap(map[string]interface{}{
+		"type": string(event.Type),
+		"diff": diff.patch,
+	}).CopyTo(record.Body().SetEmptyMapVal())
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func generateDiffPods() (*unstructured.Unstructured, *unstructured.Unstructured) {
+	previous := generateVersionedPod("pod1", "1")
+	previous.SetLabels(map[string]string{"app": "web", "app.kubernetes.io/tier": "frontend"})
+	previous.Object["spec"] = map[string]interface{}{
+		"nodeName":   "node1",
+		"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.22"}},
+	}
+	current := previous.DeepCopy()
+	current.SetResourceVersion("2")
+	current.SetLabels(map[string]string{"app": "web", "app.kubernetes.io/tier": "backend", "version": "v2"})
+	current.Object["spec"] = map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.23"}},
+	}
+	return previous, current
+}
+
+func TestObjectDiff(t *testing.T) {
+	t.Parallel()
+
+	previous, current := generateDiffPods()
+	changedFields := []string{
+		"/metadata/labels/app.kubernetes.io~1tier",
+		"/metadata/labels/version",
+		"/spec/containers",
+		"/spec/nodeName",
+	}
+
+	diff, err := newObjectDiff(DiffJSONPatch, previous, current)
+	require.NoError(t, err)
+	assert.Equal(t, changedFields, diff.changedFields)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"op": "replace", "path": "/metadata/labels/app.kubernetes.io~1tier", "value": "backend"},
+		map[string]interface{}{"op": "add", "path": "/metadata/labels/version", "value": "v2"},
+		map[string]interface{}{"op": "replace", "path": "/spec/containers", "value": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.23"}}},
+		map[string]interface{}{"op": "remove", "path": "/spec/nodeName"},
+	}, diff.patch)
+
+	diff, err = newObjectDiff(DiffMergePatch, previous, current)
+	require.NoError(t, err)
+	assert.Equal(t, changedFields, diff.changedFields)
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app.kubernetes.io/tier": "backend", "version": "v2"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.23"}},
+			"nodeName":   nil,
+		},
+	}, diff.patch)
+}
+
+func TestWatchDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		diffOnly bool
+	}{
+		{name: "object"},
+		{name: "diff_only", diffOnly: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			consumer := newMockLogConsumer()
+			rCfg := createDefaultConfig().(*Config)
+			rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+			r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+			require.NoError(t, err)
+			kr := r.(*k8sobjectreceiver)
+
+			previous, current := generateDiffPods()
+			resource := &watchResource{results: make(chan watchResult), pods: []*unstructured.Unstructured{previous}}
+			stopperChan := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, Diff: DiffMergePatch, DiffOnly: tt.diffOnly}, podsGVR, "", stopperChan, resource)
+			}()
+
+			// The listed pod is the previous version of the modified pod.
+			w := watch.NewFake()
+			resource.results <- watchResult{watcher: w}
+			w.Modify(current)
+			close(stopperChan)
+			<-done
+
+			consumer.Lock()
+			defer consumer.Unlock()
+			require.Len(t, consumer.Logs, 2)
+			assert.Equal(t, []string{"ADDED", "MODIFIED"}, recordTypes(consumer.Logs))
+
+			record := consumer.Logs[1].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+			format, ok := record.Attributes().Get("k8s.object.diff_format")
+			require.True(t, ok)
+			assert.Equal(t, "merge_patch", format.StringVal())
+			changedFields, ok := record.Attributes().Get("k8s.object.changed_fields")
+			require.True(t, ok)
+			assert.Equal(t, 4, changedFields.SliceVal().Len())
+
+			_, hasObject := record.Body().MapVal().Get("object")
+			patch, hasPatch := record.Attributes().Get("k8s.object.diff")
+			diff, hasDiff := record.Body().MapVal().Get("diff")
+			if tt.diffOnly {
+				assert.False(t, hasObject)
+				assert.False(t, hasPatch)
+				require.True(t, hasDiff)
+				_, ok = diff.MapVal().Get("spec")
+				assert.True(t, ok)
+				return
+			}
+			assert.True(t, hasObject)
+			assert.False(t, hasDiff)
+			require.True(t, hasPatch)
+			assert.JSONEq(t, `{"metadata":{"labels":{"app.kubernetes.io/tier":"backend","version":"v2"}},"spec":{"containers":[{"image":"nginx:1.23","name":"web"}],"nodeName":null}}`, patch.StringVal())
+		})
+	}
+}
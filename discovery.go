@@ -0,0 +1,320 @@
+package k8sobjectreceiver
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	errResourceNotFound  = errors.New("resource not found")
+	errAmbiguousResource = errors.New("ambiguous resource")
+)
+
+// coreGroup is the group setting that selects the core group, whose
+// resources have an empty group. Objects without a group setting are not
+// narrowed down to a group.
+const coreGroup = "core"
+
+// apiResource is a resource returned by API discovery.
+type apiResource struct {
+	gvr      schema.GroupVersionResource
+	resource metav1.APIResource
+}
+
+// discoveredResources is the result of the API discovery of a cluster.
+type discoveredResources struct {
+	resources apiResources
+	// failedGroups holds the groups whose discovery failed.
+	failedGroups map[schema.GroupVersion]error
+}
+
+// find resolves the resource an object refers to. Objects that are not
+// found and may belong to a group that failed discovery get an error
+// naming those groups.
+func (d *discoveredResources) find(object *K8sObjectsConfig) (*apiResource, error) {
+	res, err := d.resources.find(object)
+	if err == nil || len(d.failedGroups) == 0 || !errors.Is(err, errResourceNotFound) {
+		return res, err
+	}
+
+	group, qualified := object.apiGroup()
+	if !qualified && object.Kind == "" {
+		if i := strings.Index(object.Name, "."); i > 0 {
+			group, qualified = object.Name[i+1:], true
+		}
+	}
+
+	var groups []string
+	for gv, groupErr := range d.failedGroups {
+		if !qualified || gv.Group == group {
+			groups = append(groups, fmt.Sprintf("%v (%v)", gv.String(), groupErr))
+		}
+	}
+	if len(groups) == 0 {
+		return nil, err
+	}
+	sort.Strings(groups)
+	return nil, fmt.Errorf("%w, discovery failed for: %v", err, strings.Join(groups, ", "))
+}
+
+// resolve returns the resources an object refers to. Patterns resolve to
+// every matching resource that supports the verbs needed by the object.
+func (d *discoveredResources) resolve(object *K8sObjectsConfig) ([]apiResource, error) {
+	if object.isPattern() {
+		return d.resources.match(object)
+	}
+
+	res, err := d.find(object)
+	if err != nil {
+		return nil, err
+	}
+	return []apiResource{*res}, nil
+}
+
+// failedGroupNames returns the sorted group versions that failed discovery.
+func (d *discoveredResources) failedGroupNames() []string {
+	names := make([]string, 0, len(d.failedGroups))
+	for gv := range d.failedGroups {
+		names = append(names, gv.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apiResources holds the resources of a cluster and resolves object
+// references against them.
+type apiResources []apiResource
+
+func newAPIResources(lists []*metav1.APIResourceList) (apiResources, error) {
+	var resources apiResources
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range list.APIResources {
+			// Subresources such as pods/log cannot be listed or watched.
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			resources = append(resources, apiResource{
+				gvr:      gv.WithResource(resource.Name),
+				resource: resource,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// find resolves the resource an object refers to. Objects can be referenced
+// by kind, by plural, singular or short name, or by a group qualified name
+// such as deployments.apps. The group and version settings of the object
+// narrow down the candidates further.
+func (r apiResources) find(object *K8sObjectsConfig) (*apiResource, error) {
+	var candidates apiResources
+	qualified := false
+	if object.Kind != "" {
+		candidates = r.filter(func(res apiResource) bool {
+			return strings.EqualFold(res.resource.Kind, object.Kind)
+		})
+	} else {
+		candidates = r.findByName(object.Name)
+		qualified = strings.Index(object.Name, ".") > 0
+	}
+
+	group, hasGroup := object.apiGroup()
+	candidates = candidates.filter(func(res apiResource) bool {
+		if hasGroup {
+			return res.gvr.Group == group
+		}
+		// A version without a group refers to the core group, unless the
+		// group is part of the name.
+		if object.Version != "" && !qualified {
+			return res.gvr.Group == ""
+		}
+		return true
+	})
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%w: %v", errResourceNotFound, object.reference())
+	case 1:
+		res := candidates[0]
+		if object.Version != "" {
+			res.gvr.Version = object.Version
+		}
+		return &res, nil
+	default:
+		groups := make(map[string]bool, len(candidates))
+		for _, candidate := range candidates {
+			if candidate.gvr.Group == "" {
+				groups[coreGroup] = true
+			} else {
+				groups[candidate.gvr.Group] = true
+			}
+		}
+		names := make([]string, 0, len(groups))
+		for group := range groups {
+			names = append(names, group)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%w: %v, set group to one of: %v", errAmbiguousResource, object.reference(), strings.Join(names, ", "))
+	}
+}
+
+// match returns the resources matching the name pattern of an object that
+// are not excluded and support the verbs required by its mode.
+func (r apiResources) match(object *K8sObjectsConfig) ([]apiResource, error) {
+	include, err := compilePattern(object.Name)
+	if err != nil {
+		return nil, err
+	}
+	excludes := make([]func(string) bool, 0, len(object.Exclude))
+	for _, exclude := range object.Exclude {
+		match, err := compilePattern(exclude)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, match)
+	}
+
+	verbs := requiredVerbs(object.Mode)
+	group, hasGroup := object.apiGroup()
+	var matched []apiResource
+	for _, res := range r {
+		if hasGroup && res.gvr.Group != group {
+			continue
+		}
+		name := res.gvr.GroupResource().String()
+		if !include(name) || !res.supports(verbs...) {
+			continue
+		}
+		excluded := false
+		for _, exclude := range excludes {
+			if exclude(name) || exclude(res.gvr.Resource) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		if object.Version != "" {
+			res.gvr.Version = object.Version
+		}
+		matched = append(matched, res)
+	}
+	return matched, nil
+}
+
+// supports returns whether the resource supports all verbs.
+func (r apiResource) supports(verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, v := range r.resource.Verbs {
+			if v == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredVerbs returns the verbs an object needs to be collected in a mode.
+// Watches list the objects before they start and when their resource
+// version expired.
+func requiredVerbs(mode Mode) []string {
+	if mode == WatchMode {
+		return []string{"list", "watch"}
+	}
+	return []string{"list"}
+}
+
+const regexPatternPrefix = "regex:"
+
+// isPattern returns whether a resource name is a glob or regex pattern.
+func isPattern(name string) bool {
+	return strings.HasPrefix(name, regexPatternPrefix) || strings.ContainsAny(name, "*?[")
+}
+
+// compilePattern returns a matcher for a glob, a regular expression
+// prefixed with "regex:", or a plain name. Patterns match whole names.
+func compilePattern(pattern string) (func(string) bool, error) {
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, regexPatternPrefix) + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+func (r apiResources) findByName(name string) apiResources {
+	// Names are matched as plural names first, as kubectl does, so that
+	// a short name of one resource does not shadow the plural of another.
+	matchers := []func(res apiResource, name string) bool{
+		func(res apiResource, name string) bool {
+			return res.resource.Name == name
+		},
+		func(res apiResource, name string) bool {
+			if res.resource.SingularName == name {
+				return true
+			}
+			for _, shortName := range res.resource.ShortNames {
+				if shortName == name {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	resource, group := name, ""
+	qualified := false
+	if i := strings.Index(name, "."); i > 0 {
+		resource, group, qualified = name[:i], name[i+1:], true
+	}
+
+	for _, match := range matchers {
+		candidates := r.filter(func(res apiResource) bool {
+			if qualified {
+				return res.gvr.Group == group && match(res, resource)
+			}
+			return match(res, name)
+		})
+		if len(candidates) > 0 {
+			return candidates
+		}
+	}
+	return nil
+}
+
+func (r apiResources) filter(keep func(res apiResource) bool) apiResources {
+	var filtered apiResources
+	for _, res := range r {
+		if keep(res) {
+			filtered = append(filtered, res)
+		}
+	}
+	return filtered
+}
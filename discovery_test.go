@@ -0,0 +1,217 @@
+package k8sobjectreceiver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFindResource(t *testing.T) {
+	t.Parallel()
+
+	dc, err := getMockDiscoveryClientWithEvents()
+	require.NoError(t, err)
+	lists, err := dc.ServerPreferredResources()
+	require.NoError(t, err)
+	resources, err := newAPIResources(lists)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		object      *K8sObjectsConfig
+		expected    *schema.GroupVersionResource
+		expectedErr string
+	}{
+		{
+			name:     "plural name",
+			object:   &K8sObjectsConfig{Name: "pods"},
+			expected: &schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		},
+		{
+			name:     "short name",
+			object:   &K8sObjectsConfig{Name: "deploy"},
+			expected: &schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		{
+			name:     "singular name",
+			object:   &K8sObjectsConfig{Name: "pod"},
+			expected: &schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		},
+		{
+			name:     "group qualified name",
+			object:   &K8sObjectsConfig{Name: "deployments.apps"},
+			expected: &schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		{
+			name:     "group qualified name with dots",
+			object:   &K8sObjectsConfig{Name: "events.events.k8s.io"},
+			expected: &schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"},
+		},
+		{
+			name:     "explicit group",
+			object:   &K8sObjectsConfig{Name: "events", Group: "events.k8s.io"},
+			expected: &schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"},
+		},
+		{
+			name:     "explicit version selects the core group",
+			object:   &K8sObjectsConfig{Name: "events", Version: "v1"},
+			expected: &schema.GroupVersionResource{Version: "v1", Resource: "events"},
+		},
+		{
+			name:     "core group",
+			object:   &K8sObjectsConfig{Name: "events", Group: "core"},
+			expected: &schema.GroupVersionResource{Version: "v1", Resource: "events"},
+		},
+		{
+			name:     "group qualified name and version",
+			object:   &K8sObjectsConfig{Name: "deployments.apps", Version: "v1"},
+			expected: &schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		{
+			name:     "explicit group and version",
+			object:   &K8sObjectsConfig{Name: "deployments", Group: "apps", Version: "v1beta2"},
+			expected: &schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "deployments"},
+		},
+		{
+			name:     "kind",
+			object:   &K8sObjectsConfig{Kind: "deployment"},
+			expected: &schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		{
+			name:        "ambiguous name",
+			object:      &K8sObjectsConfig{Name: "events"},
+			expectedErr: "ambiguous resource: events, set group to one of: core, events.k8s.io",
+		},
+		{
+			name:        "ambiguous short name",
+			object:      &K8sObjectsConfig{Name: "ev"},
+			expectedErr: "ambiguous resource: ev",
+		},
+		{
+			name:        "subresource",
+			object:      &K8sObjectsConfig{Name: "pods/log"},
+			expectedErr: "resource not found: pods/log",
+		},
+		{
+			name:        "unknown group",
+			object:      &K8sObjectsConfig{Name: "pods.apps"},
+			expectedErr: "resource not found: pods.apps",
+		},
+		{
+			name:        "unknown kind",
+			object:      &K8sObjectsConfig{Kind: "Certificate"},
+			expectedErr: "resource not found: Certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resources.find(tt.object)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, &res.gvr)
+		})
+	}
+}
+
+func TestFindResourceWithFailedGroups(t *testing.T) {
+	t.Parallel()
+
+	dc := &MockDiscovery{
+		failedGroups: map[schema.GroupVersion]error{
+			{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("service unavailable"),
+		},
+	}
+	resources, err := (&ClusterConfig{makeDiscoveryClient: dc.getMockDiscoveryClient}).getValidObjects(clientSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metrics.k8s.io/v1beta1"}, resources.failedGroupNames())
+
+	// Objects of other groups are still resolved.
+	res, err := resources.find(&K8sObjectsConfig{Name: "pods"})
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "pods"}, res.gvr)
+
+	_, err = resources.find(&K8sObjectsConfig{Name: "pods.metrics.k8s.io"})
+	assert.ErrorIs(t, err, errResourceNotFound)
+	assert.ErrorContains(t, err, "discovery failed for: metrics.k8s.io/v1beta1 (service unavailable)")
+
+	_, err = resources.find(&K8sObjectsConfig{Kind: "PodMetrics"})
+	assert.ErrorContains(t, err, "discovery failed for: metrics.k8s.io/v1beta1")
+
+	// Objects of a group that was discovered do not depend on the failed group.
+	_, err = resources.find(&K8sObjectsConfig{Name: "replicasets", Group: "apps"})
+	assert.EqualError(t, err, "resource not found: replicasets")
+}
+
+func TestMatchResources(t *testing.T) {
+	t.Parallel()
+
+	dc, err := getMockDiscoveryClientWithCRDs()
+	require.NoError(t, err)
+	lists, err := dc.ServerPreferredResources()
+	require.NoError(t, err)
+	resources, err := newAPIResources(lists)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		object   *K8sObjectsConfig
+		expected []string
+	}{
+		{
+			name:     "all resources",
+			object:   &K8sObjectsConfig{Name: "*", Mode: PullMode},
+			expected: []string{"pods", "events", "deployments.apps", "certificates.cert-manager.io", "issuers.cert-manager.io", "challenges.acme.cert-manager.io"},
+		},
+		{
+			name:     "all resources with exclusions",
+			object:   &K8sObjectsConfig{Name: "*", Mode: PullMode, Exclude: []string{"events", "*.cert-manager.io"}},
+			expected: []string{"pods", "deployments.apps"},
+		},
+		{
+			name:     "glob over group",
+			object:   &K8sObjectsConfig{Name: "*.cert-manager.io", Mode: PullMode},
+			expected: []string{"certificates.cert-manager.io", "issuers.cert-manager.io", "challenges.acme.cert-manager.io"},
+		},
+		{
+			name:     "resources without the watch verb",
+			object:   &K8sObjectsConfig{Name: "*.cert-manager.io", Mode: WatchMode},
+			expected: []string{"certificates.cert-manager.io", "issuers.cert-manager.io"},
+		},
+		{
+			name:     "regex",
+			object:   &K8sObjectsConfig{Name: `regex:.*\.cert-manager\.io`, Mode: PullMode, Exclude: []string{"regex:issuers|challenges"}},
+			expected: []string{"certificates.cert-manager.io"},
+		},
+		{
+			name:     "explicit group",
+			object:   &K8sObjectsConfig{Name: "*", Group: "cert-manager.io", Mode: PullMode},
+			expected: []string{"certificates.cert-manager.io", "issuers.cert-manager.io"},
+		},
+		{
+			name:     "core group",
+			object:   &K8sObjectsConfig{Name: "*", Group: "core", Mode: PullMode},
+			expected: []string{"pods", "events"},
+		},
+		{
+			name:   "no match",
+			object: &K8sObjectsConfig{Name: "*.example.com", Mode: PullMode},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := resources.match(tt.object)
+			require.NoError(t, err)
+			var names []string
+			for _, res := range matched {
+				names = append(names, res.gvr.GroupResource().String())
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
@@ -2,7 +2,9 @@ package k8sobjectreceiver
 
 import (
 	"context"
+	"time"
 
+	"go.opencensus.io/stats/view"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/consumer"
@@ -11,9 +13,15 @@ import (
 const (
 	typeStr   = "k8sobjects"
 	stability = component.StabilityLevelAlpha
+
+	defaultDiscoveryInterval = 10 * time.Minute
+	// defaultPullInterval is the interval of pull objects without an
+	// interval or schedule.
+	defaultPullInterval = time.Hour
 )
 
 func NewFactory() component.ReceiverFactory {
+	_ = view.Register(metricViews()...)
 	return component.NewReceiverFactory(
 		typeStr,
 		createDefaultConfig,
@@ -27,6 +35,11 @@ func NewFactory() component.ReceiverFactory {
 func createDefaultConfig() config.Receiver {
 	return &Config{
 		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		APIConfig: APIConfig{
+			AuthType: AuthTypeServiceAccount,
+		},
+		DiscoveryInterval: defaultDiscoveryInterval,
+		PermissionCheck:   PermissionCheckLenient,
 	}
 }
 
@@ -3,6 +3,7 @@ package k8sobjectreceiver
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,6 +20,11 @@ func TestDefaultConfig(t *testing.T) {
 
 	assert.Equal(t, &Config{
 		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID("k8sobjects")),
+		APIConfig: APIConfig{
+			AuthType: AuthTypeServiceAccount,
+		},
+		DiscoveryInterval: 10 * time.Minute,
+		PermissionCheck:   PermissionCheckLenient,
 	}, rCfg)
 }
 
@@ -0,0 +1,187 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WatchBackend is how watch mode receives the events of the objects.
+type WatchBackend string
+
+const (
+	// WatchBackendWatch watches the resource of every object directly.
+	WatchBackendWatch WatchBackend = "watch"
+	// WatchBackendInformer receives the events from shared informers, which
+	// handle relists and reconnections. Objects that watch the same
+	// resource in the same namespace with the same selectors share a
+	// single watch. Informers keep every object in memory and always
+	// start from a list, so they are opt-in.
+	WatchBackendInformer WatchBackend = "informer"
+)
+
+var watchBackendMap = map[WatchBackend]bool{
+	WatchBackendWatch:    true,
+	WatchBackendInformer: true,
+}
+
+// informerKey identifies the informers that can be shared, they watch a
+// resource in a namespace with the same selectors.
+type informerKey struct {
+	gvr           schema.GroupVersionResource
+	namespace     string
+	labelSelector string
+	fieldSelector string
+}
+
+// sharedInformer is an informer with the number of collections that use
+// it. Every collection adds its own event handler, which the informer sends
+// the objects of its store as added before any later event. Handlers cannot
+// be removed from informers, the handlers of stopped collections ignore the
+// events until the informer stops with its last collection.
+type sharedInformer struct {
+	informer    cache.SharedIndexInformer
+	handlers    int
+	stopperChan chan struct{}
+}
+
+// sharedInformers holds the informers of a cluster that are in use.
+type sharedInformers struct {
+	mu        sync.Mutex
+	client    dynamic.Interface
+	informers map[informerKey]*sharedInformer
+}
+
+func newSharedInformers(client dynamic.Interface) *sharedInformers {
+	return &sharedInformers{
+		client:    client,
+		informers: make(map[informerKey]*sharedInformer),
+	}
+}
+
+// add adds a handler to the informer of a resource in a namespace with the
+// selectors of an object and returns the key of the informer. The informer
+// is created and started, with a channel from newStopperChan, when it is
+// not in use yet.
+func (s *sharedInformers) add(config *K8sObjectsConfig, gvr schema.GroupVersionResource, namespace string, h *informerHandler, newStopperChan func() chan struct{}) informerKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := informerKey{
+		gvr:           gvr,
+		namespace:     namespace,
+		labelSelector: config.LabelSelector,
+		fieldSelector: config.fieldSelector(),
+	}
+	informer, ok := s.informers[key]
+	if !ok {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(s.client, 0, namespace, func(opts *metav1.ListOptions) {
+			opts.LabelSelector = key.labelSelector
+			opts.FieldSelector = key.fieldSelector
+		})
+		informer = &sharedInformer{
+			informer:    factory.ForResource(gvr).Informer(),
+			stopperChan: newStopperChan(),
+		}
+		factory.Start(informer.stopperChan)
+		s.informers[key] = informer
+	}
+	informer.informer.AddEventHandler(h)
+	informer.handlers++
+	return key
+}
+
+// remove releases an informer for a stopped collection. The informer is
+// stopped with closeStopperChan once no collection uses it.
+func (s *sharedInformers) remove(key informerKey, closeStopperChan func(chan struct{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	informer, ok := s.informers[key]
+	if !ok {
+		return
+	}
+	informer.handlers--
+	if informer.handlers > 0 {
+		return
+	}
+	delete(s.informers, key)
+	closeStopperChan(informer.stopperChan)
+}
+
+// startInformer sends the events of the shared informer of the resource
+// until the collection is stopped. The objects already known to the
+// informer are sent as added.
+func (kr *k8sobjectreceiver) startInformer(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, namespace string, stopperChan chan struct{}) {
+	handler := &informerHandler{
+		ctx:         ctx,
+		kr:          kr,
+		cluster:     cluster,
+		config:      config,
+		gvr:         gvr,
+		stopperChan: stopperChan,
+	}
+	key := cluster.informers.add(config, gvr, namespace, handler, kr.newStopperChan)
+	go func() {
+		<-stopperChan
+		cluster.informers.remove(key, kr.closeStopperChan)
+	}()
+}
+
+// informerHandler sends the events of an informer for a single collection.
+// A stopped handler ignores the events until it is removed.
+type informerHandler struct {
+	ctx         context.Context
+	kr          *k8sobjectreceiver
+	cluster     *cluster
+	config      *K8sObjectsConfig
+	gvr         schema.GroupVersionResource
+	stopperChan chan struct{}
+}
+
+func (h *informerHandler) OnAdd(obj interface{}) {
+	h.send(watch.Added, obj, nil)
+}
+
+// OnUpdate skips the resyncs of objects that did not change. The previous
+// version of the informer is used for diffs.
+func (h *informerHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldObject, ok := oldObj.(*unstructured.Unstructured)
+	if newObject, isObject := newObj.(*unstructured.Unstructured); ok && isObject && oldObject.GetResourceVersion() == newObject.GetResourceVersion() {
+		return
+	}
+	h.send(watch.Modified, newObj, oldObject)
+}
+
+// OnDelete sends the last known state of an object whose deletion was
+// missed while the informer was disconnected.
+func (h *informerHandler) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	h.send(watch.Deleted, obj, nil)
+}
+
+func (h *informerHandler) send(eventType watch.EventType, obj interface{}, previous *unstructured.Unstructured) {
+	select {
+	case <-h.stopperChan:
+		return
+	default:
+	}
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		h.kr.setting.Logger.Warn("unexpected object in informer event", zap.String("cluster", h.cluster.name), zap.String("resource", h.gvr.String()), zap.String("type", string(eventType)))
+		return
+	}
+	out, err := modifiedEventToLogData(h.config, watch.Event{Type: eventType, Object: object}, previous, h.cluster.name)
+	if err != nil {
+		h.kr.setting.Logger.Warn("error in diffing object", zap.String("cluster", h.cluster.name), zap.String("resource", h.gvr.String()), zap.Error(err))
+	}
+	h.kr.consumer.ConsumeLogs(h.ctx, out)
+}
@@ -0,0 +1,213 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// recordTypes returns the event types of the records, in order.
+func recordTypes(logs []plog.Logs) []string {
+	var types []string
+	for _, ld := range logs {
+		records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < records.Len(); i++ {
+			eventType, _ := records.At(i).Body().MapVal().Get("type")
+			types = append(types, eventType.StringVal())
+		}
+	}
+	return types
+}
+
+func TestInformerSharedWatch(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(generateVersionedPod("pod1", "1"))
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+	cluster := kr.clusters[0]
+
+	count := func() int {
+		consumer.Lock()
+		defer consumer.Unlock()
+		return consumer.Count
+	}
+
+	first := &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchBackend: WatchBackendInformer}
+	second := &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchBackend: WatchBackendInformer}
+	stopperChan := make(chan struct{})
+	kr.startInformer(context.Background(), cluster, first, podsGVR, metav1.NamespaceAll, stopperChan)
+	require.Eventually(t, func() bool { return count() == 1 }, time.Second, time.Millisecond)
+
+	// The second object shares the informer and receives the known pod.
+	kr.startInformer(context.Background(), cluster, second, podsGVR, metav1.NamespaceAll, make(chan struct{}))
+	require.Eventually(t, func() bool { return count() == 2 }, time.Second, time.Millisecond)
+	require.Len(t, cluster.informers.informers, 1)
+
+	// The stopped collection ignores the events.
+	close(stopperChan)
+	pods := cluster.client.Resource(podsGVR).Namespace("default")
+	_, err = pods.Update(context.Background(), generateVersionedPod("pod1", "2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return count() == 3 }, time.Second, time.Millisecond)
+	require.NoError(t, pods.Delete(context.Background(), "pod1", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool { return count() == 4 }, time.Second, time.Millisecond)
+
+	require.NoError(t, kr.Shutdown(context.Background()))
+
+	consumer.Lock()
+	defer consumer.Unlock()
+	assert.Equal(t, []string{"ADDED", "ADDED", "MODIFIED", "DELETED"}, recordTypes(consumer.Logs))
+}
+
+func TestInformerJoinWhileSending(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(generateVersionedPod("pod1", "1"), generateVersionedPod("pod2", "1"), generateVersionedPod("pod3", "1"))
+
+	consumer := &gatedConsumer{mockLogConsumer: newMockLogConsumer(), entered: make(chan struct{}), release: make(chan struct{})}
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	count := func() int {
+		consumer.Lock()
+		defer consumer.Unlock()
+		return consumer.Count
+	}
+	start := func() {
+		object := &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchBackend: WatchBackendInformer}
+		kr.startInformer(context.Background(), kr.clusters[0], object, podsGVR, metav1.NamespaceAll, kr.newStopperChan())
+	}
+
+	// The second collection joins while the first one is sending the
+	// first pod and the other pods are already known to the informer.
+	start()
+	<-consumer.entered
+	go start()
+	time.Sleep(50 * time.Millisecond)
+	close(consumer.release)
+
+	// Every collection gets each pod once.
+	require.Eventually(t, func() bool { return count() == 6 }, time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 6, count())
+	require.NoError(t, kr.Shutdown(context.Background()))
+}
+
+// gatedConsumer blocks the first logs it consumes until it is released.
+type gatedConsumer struct {
+	*mockLogConsumer
+	once    sync.Once
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *gatedConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	c.once.Do(func() {
+		close(c.entered)
+		<-c.release
+	})
+	return c.mockLogConsumer.ConsumeLogs(ctx, ld)
+}
+
+func TestInformerHandlerTombstone(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	handler := &informerHandler{
+		ctx:         context.Background(),
+		kr:          kr,
+		cluster:     kr.clusters[0],
+		config:      &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchBackend: WatchBackendInformer},
+		gvr:         podsGVR,
+		stopperChan: make(chan struct{}),
+	}
+	pod := generateVersionedPod("pod1", "1")
+	handler.OnUpdate(pod, pod)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/pod1", Obj: pod})
+
+	require.Len(t, consumer.Logs, 1)
+	assert.Equal(t, []string{"DELETED"}, recordTypes(consumer.Logs))
+	object, ok := consumer.Logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().MapVal().Get("object")
+	require.True(t, ok)
+	metadata, ok := object.MapVal().Get("metadata")
+	require.True(t, ok)
+	name, ok := metadata.MapVal().Get("name")
+	require.True(t, ok)
+	assert.Equal(t, "pod1", name.StringVal())
+}
+
+func TestInformerStopsWhenUnused(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(generateVersionedPod("pod1", "1"))
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+	cluster := kr.clusters[0]
+
+	count := func() int {
+		consumer.Lock()
+		defer consumer.Unlock()
+		return consumer.Count
+	}
+	informers := func() int {
+		cluster.informers.mu.Lock()
+		defer cluster.informers.mu.Unlock()
+		return len(cluster.informers.informers)
+	}
+	stopperChans := func() int {
+		kr.mu.Lock()
+		defer kr.mu.Unlock()
+		return len(kr.stopperChanList)
+	}
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchBackend: WatchBackendInformer}
+	first := kr.newStopperChan()
+	kr.startInformer(context.Background(), cluster, object, podsGVR, "default", first)
+	require.Eventually(t, func() bool { return count() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 2, stopperChans())
+
+	// The informer is stopped with its last collection.
+	kr.closeStopperChan(first)
+	require.Eventually(t, func() bool { return informers() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, stopperChans())
+
+	// A collection started again gets a new informer.
+	second := kr.newStopperChan()
+	kr.startInformer(context.Background(), cluster, object, podsGVR, "default", second)
+	require.Eventually(t, func() bool { return count() == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, informers())
+
+	require.NoError(t, kr.Shutdown(context.Background()))
+	require.Eventually(t, func() bool { return informers() == 0 }, time.Second, time.Millisecond)
+}
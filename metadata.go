@@ -0,0 +1,108 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/metadata"
+)
+
+// Content is which part of the objects is collected.
+type Content string
+
+const (
+	// ContentFull collects the whole objects.
+	ContentFull Content = "full"
+	// ContentMetadata only collects the metadata of the objects, e.g. names,
+	// labels, annotations and owner references, through the metadata API.
+	ContentMetadata Content = "metadata"
+)
+
+var contentMap = map[Content]bool{
+	ContentFull:     true,
+	ContentMetadata: true,
+}
+
+// objectResource lists and watches the objects of a resource.
+type objectResource interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// metadataResource serves the metadata of the objects of a resource as
+// unstructured objects that only hold the apiVersion, kind and metadata.
+type metadataResource struct {
+	resource   metadata.ResourceInterface
+	apiVersion string
+	kind       string
+}
+
+func newMetadataResource(resource metadata.ResourceInterface, gvr schema.GroupVersionResource, kind string) *metadataResource {
+	return &metadataResource{
+		resource:   resource,
+		apiVersion: gvr.GroupVersion().String(),
+		kind:       kind,
+	}
+}
+
+func (r *metadataResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, err := r.resource.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	out.SetAPIVersion(r.apiVersion)
+	out.SetKind(r.kind + "List")
+	out.SetResourceVersion(list.GetResourceVersion())
+	out.SetContinue(list.GetContinue())
+	out.SetRemainingItemCount(list.GetRemainingItemCount())
+	out.Items = make([]unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		item, err := r.toUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, *item)
+	}
+	return out, nil
+}
+
+// Watch converts the objects of the events, error events are passed on
+// unchanged.
+func (r *metadataResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := r.resource.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		object, ok := in.Object.(*metav1.PartialObjectMetadata)
+		if !ok {
+			return in, true
+		}
+		item, err := r.toUnstructured(object)
+		if err != nil {
+			return watch.Event{Type: watch.Error, Object: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}}, true
+		}
+		return watch.Event{Type: in.Type, Object: item}, true
+	}), nil
+}
+
+func (r *metadataResource) toUnstructured(object *metav1.PartialObjectMetadata) (*unstructured.Unstructured, error) {
+	objectMeta, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&object.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": r.apiVersion,
+		"kind":       r.kind,
+		"metadata":   objectMeta,
+	}}, nil
+}
@@ -0,0 +1,63 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestMetadataResourceList(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockMetadataClient(
+		generatePodMetadata("pod1", "default", map[string]string{"app": "web"}),
+		generatePodMetadata("pod2", "default", nil),
+		generatePodMetadata("pod3", "other", nil),
+	)
+	resource := newMetadataResource(mockClient.client.Resource(podsMetadataGVR).Namespace("default"), podsMetadataGVR, "Pod")
+
+	list, err := resource.List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", list.GetAPIVersion())
+	assert.Equal(t, "PodList", list.GetKind())
+	require.Len(t, list.Items, 2)
+
+	pod := list.Items[0]
+	assert.Equal(t, "Pod", pod.GetKind())
+	assert.Equal(t, "v1", pod.GetAPIVersion())
+	assert.Equal(t, "pod1", pod.GetName())
+	assert.Equal(t, map[string]string{"app": "web"}, pod.GetLabels())
+	for key := range pod.Object {
+		assert.Contains(t, []string{"apiVersion", "kind", "metadata"}, key)
+	}
+}
+
+func TestMetadataResourceWatch(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockMetadataClient()
+	resource := newMetadataResource(mockClient.client.Resource(podsMetadataGVR).Namespace("default"), podsMetadataGVR, "Pod")
+
+	w, err := resource.Watch(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	mockClient.createPods(generatePodMetadata("pod1", "default", nil))
+
+	select {
+	case event := <-w.ResultChan():
+		assert.Equal(t, watch.Added, event.Type)
+		pod, ok := event.Object.(*unstructured.Unstructured)
+		require.True(t, ok)
+		assert.Equal(t, "Pod", pod.GetKind())
+		assert.Equal(t, "pod1", pod.GetName())
+	case <-time.After(time.Second):
+		t.Fatal("no watch event")
+	}
+}
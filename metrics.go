@@ -0,0 +1,70 @@
+package k8sobjectreceiver
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	tagReceiverKey  = tag.MustNewKey("receiver")
+	tagClusterKey   = tag.MustNewKey("cluster")
+	tagResourceKey  = tag.MustNewKey("resource")
+	tagNamespaceKey = tag.MustNewKey("namespace")
+	tagReasonKey    = tag.MustNewKey("reason")
+
+	mClientThrottleWait = stats.Float64(
+		typeStr+"/client_throttle_wait",
+		"Time API requests waited on the client-side rate limiter",
+		stats.UnitMilliseconds,
+	)
+	mPullInterval = stats.Float64(
+		typeStr+"/pull_interval",
+		"Effective interval between the pulls of an object with an adaptive interval",
+		stats.UnitSeconds,
+	)
+	mWatchErrors = stats.Int64(
+		typeStr+"/watch_errors",
+		"Number of error events received by watches, by status reason",
+		stats.UnitDimensionless,
+	)
+	mWatchRestarts = stats.Int64(
+		typeStr+"/watch_restarts",
+		"Number of times a watch was restarted",
+		stats.UnitDimensionless,
+	)
+)
+
+// metricViews returns the views of the receiver self-metrics.
+func metricViews() []*view.View {
+	return []*view.View{
+		{
+			Name:        mClientThrottleWait.Name(),
+			Description: mClientThrottleWait.Description(),
+			Measure:     mClientThrottleWait,
+			TagKeys:     []tag.Key{tagReceiverKey, tagClusterKey},
+			Aggregation: view.Distribution(0, 1, 5, 10, 50, 100, 500, 1000, 5000),
+		},
+		{
+			Name:        mPullInterval.Name(),
+			Description: mPullInterval.Description(),
+			Measure:     mPullInterval,
+			TagKeys:     []tag.Key{tagReceiverKey, tagClusterKey, tagResourceKey, tagNamespaceKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        mWatchErrors.Name(),
+			Description: mWatchErrors.Description(),
+			Measure:     mWatchErrors,
+			TagKeys:     []tag.Key{tagReceiverKey, tagClusterKey, tagResourceKey, tagNamespaceKey, tagReasonKey},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWatchRestarts.Name(),
+			Description: mWatchRestarts.Description(),
+			Measure:     mWatchRestarts,
+			TagKeys:     []tag.Key{tagReceiverKey, tagClusterKey, tagResourceKey, tagNamespaceKey, tagReasonKey},
+			Aggregation: view.Sum(),
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package k8sobjectreceiver
+
+import (
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	typedauthorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// mockAccessReviewClient allows every access review except the denied
+// namespace/verb pairs.
+type mockAccessReviewClient struct {
+	denied map[string]bool
+}
+
+func newMockAccessReviewClient(denied ...string) mockAccessReviewClient {
+	c := mockAccessReviewClient{denied: make(map[string]bool)}
+	for _, d := range denied {
+		c.denied[d] = true
+	}
+	return c
+}
+
+func (c mockAccessReviewClient) getMockAccessReviewClient() (typedauthorizationv1.SelfSubjectAccessReviewInterface, error) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = !c.denied[attrs.Namespace+"/"+attrs.Resource+"/"+attrs.Verb]
+		if !review.Status.Allowed {
+			review.Status.Reason = "RBAC: access denied"
+		}
+		return true, review, nil
+	})
+	return client.AuthorizationV1().SelfSubjectAccessReviews(), nil
+}
@@ -1,27 +1,119 @@
 package k8sobjectreceiver
 
 import (
+	"errors"
+	"sync"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	fakeDiscovery "k8s.io/client-go/discovery/fake"
 )
 
 type MockDiscovery struct {
 	fakeDiscovery.FakeDiscovery
+	mu             sync.Mutex
+	failures       int
+	failedGroups   map[schema.GroupVersion]error
+	extraResources []*metav1.APIResourceList
 }
 
 func (c *MockDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
-	return []*metav1.APIResourceList{
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return nil, errors.New("connection refused")
+	}
+	var err error
+	if len(c.failedGroups) > 0 {
+		err = &discovery.ErrGroupDiscoveryFailed{Groups: c.failedGroups}
+	}
+	return append([]*metav1.APIResourceList{
 		{
 			GroupVersion: "v1",
 			APIResources: []metav1.APIResource{
 				{
-					Name: "pods",
-					Kind: "Pods",
+					Name:         "pods",
+					SingularName: "pod",
+					ShortNames:   []string{"po"},
+					Kind:         "Pods",
+					Namespaced:   true,
+					Verbs:        []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+				},
+				{
+					Name:       "pods/log",
+					Kind:       "Pods",
+					Namespaced: true,
+					Verbs:      []string{"get"},
 				},
 				{
-					Name: "events",
-					Kind: "Events",
+					Name:         "events",
+					SingularName: "event",
+					ShortNames:   []string{"ev"},
+					Kind:         "Events",
+					Namespaced:   true,
+					Verbs:        []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+				},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{
+					Name:         "deployments",
+					SingularName: "deployment",
+					ShortNames:   []string{"deploy"},
+					Kind:         "Deployment",
+					Namespaced:   true,
+					Verbs:        []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+				},
+			},
+		},
+	}, c.extraResources...), err
+}
+
+// addResources makes resources available to subsequent discovery calls.
+func (c *MockDiscovery) addResources(resources ...*metav1.APIResourceList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extraResources = append(c.extraResources, resources...)
+}
+
+func (c *MockDiscovery) getMockDiscoveryClient() (discovery.ServerResourcesInterface, error) {
+	return c, nil
+}
+
+// getMockDiscoveryClientWithCRDs adds custom resources to the resources.
+func getMockDiscoveryClientWithCRDs() (discovery.ServerResourcesInterface, error) {
+	return &MockDiscovery{
+		extraResources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "cert-manager.io/v1",
+				APIResources: []metav1.APIResource{
+					{
+						Name:       "certificates",
+						Kind:       "Certificate",
+						Namespaced: true,
+						Verbs:      []string{"get", "list", "watch"},
+					},
+					{
+						Name:       "issuers",
+						Kind:       "Issuer",
+						Namespaced: true,
+						Verbs:      []string{"get", "list", "watch"},
+					},
+				},
+			},
+			{
+				GroupVersion: "acme.cert-manager.io/v1",
+				APIResources: []metav1.APIResource{
+					{
+						Name:       "challenges",
+						Kind:       "Challenge",
+						Namespaced: true,
+						Verbs:      []string{"get", "list"},
+					},
 				},
 			},
 		},
@@ -31,3 +123,25 @@ func (c *MockDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, e
 func getMockDiscoveryClient() (discovery.ServerResourcesInterface, error) {
 	return &MockDiscovery{}, nil
 }
+
+// getMockDiscoveryClientWithEvents adds events.k8s.io/v1 events to the
+// resources, which collides with the core events.
+func getMockDiscoveryClientWithEvents() (discovery.ServerResourcesInterface, error) {
+	return &MockDiscovery{
+		extraResources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "events.k8s.io/v1",
+				APIResources: []metav1.APIResource{
+					{
+						Name:         "events",
+						SingularName: "event",
+						ShortNames:   []string{"ev"},
+						Kind:         "Event",
+						Namespaced:   true,
+						Verbs:        []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+					},
+				},
+			},
+		},
+	}, nil
+}
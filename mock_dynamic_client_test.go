@@ -20,7 +20,9 @@ func newMockDynamicClient() mockDynamicClient {
 	objs := []runtime.Object{}
 
 	gvrToListKind := map[schema.GroupVersionResource]string{
-		{Group: "", Version: "v1", Resource: "pods"}: "PodList",
+		{Group: "", Version: "v1", Resource: "pods"}:                        "PodList",
+		{Group: "", Version: "v1", Resource: "namespaces"}:                  "NamespaceList",
+		{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}: "CertificateList",
 	}
 
 	fakeClient := fake.NewSimpleDynamicClientWithCustomListKinds(scheme, gvrToListKind, objs...)
@@ -44,6 +46,26 @@ func (c mockDynamicClient) createPods(objects ...*unstructured.Unstructured) {
 	}
 }
 
+func (c mockDynamicClient) createObjects(gvr schema.GroupVersionResource, objects ...*unstructured.Unstructured) {
+	resource := c.client.Resource(gvr)
+	for _, object := range objects {
+		resource.Namespace(object.GetNamespace()).Create(context.Background(), object, v1.CreateOptions{})
+	}
+}
+
+func generateNamespace(name string, labels map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": labels,
+			},
+		},
+	}
+}
+
 func generatePod(name, namespace string, labels map[string]interface{}) *unstructured.Unstructured {
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
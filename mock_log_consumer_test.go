@@ -2,12 +2,14 @@ package k8sobjectreceiver
 
 import (
 	"context"
+	"sync"
 
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/plog"
 )
 
 type mockLogConsumer struct {
+	sync.Mutex
 	Logs  []plog.Logs
 	Count int
 }
@@ -25,6 +27,8 @@ func (m *mockLogConsumer) Capabilities() consumer.Capabilities {
 }
 
 func (m *mockLogConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	m.Lock()
+	defer m.Unlock()
 	m.Logs = append(m.Logs, ld)
 	m.Count += ld.LogRecordCount()
 	return nil
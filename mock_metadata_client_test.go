@@ -0,0 +1,47 @@
+package k8sobjectreceiver
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/fake"
+)
+
+type mockMetadataClient struct {
+	client *fake.FakeMetadataClient
+}
+
+func newMockMetadataClient(objects ...runtime.Object) mockMetadataClient {
+	scheme := fake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	return mockMetadataClient{
+		client: fake.NewSimpleMetadataClient(scheme, objects...),
+	}
+}
+
+func (c mockMetadataClient) getMockMetadataClient() (metadata.Interface, error) {
+	return c.client, nil
+}
+
+func (c mockMetadataClient) createPods(objects ...*metav1.PartialObjectMetadata) {
+	for _, pod := range objects {
+		pods := c.client.Resource(podsMetadataGVR).Namespace(pod.Namespace).(fake.MetadataClient)
+		pods.CreateFake(pod, metav1.CreateOptions{})
+	}
+}
+
+func generatePodMetadata(name, namespace string, labels map[string]string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			Labels:          labels,
+			ResourceVersion: "1",
+		},
+	}
+}
+
+// podsMetadataGVR is the resource of the pods created by generatePodMetadata.
+var podsMetadataGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
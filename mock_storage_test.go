@@ -0,0 +1,91 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+)
+
+// mockStorageClient keeps the data in memory. Operations fail once the
+// client is closed, and are counted in afterClose.
+type mockStorageClient struct {
+	mu         sync.Mutex
+	data       map[string][]byte
+	closed     bool
+	afterClose int
+}
+
+func newMockStorageClient() *mockStorageClient {
+	return &mockStorageClient{data: make(map[string][]byte)}
+}
+
+func (c *mockStorageClient) Get(ctx context.Context, key string) ([]byte, error) {
+	op := storage.GetOperation(key)
+	err := c.Batch(ctx, op)
+	return op.Value, err
+}
+
+func (c *mockStorageClient) Set(ctx context.Context, key string, value []byte) error {
+	return c.Batch(ctx, storage.SetOperation(key, value))
+}
+
+func (c *mockStorageClient) Delete(ctx context.Context, key string) error {
+	return c.Batch(ctx, storage.DeleteOperation(key))
+}
+
+func (c *mockStorageClient) Batch(_ context.Context, ops ...storage.Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.afterClose++
+		return errors.New("client closed")
+	}
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value = c.data[op.Key]
+		case storage.Set:
+			c.data[op.Key] = op.Value
+		case storage.Delete:
+			delete(c.data, op.Key)
+		}
+	}
+	return nil
+}
+
+func (c *mockStorageClient) Close(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *mockStorageClient) get(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.data[key])
+}
+
+// mockStorageExtension returns its client to every component.
+type mockStorageExtension struct {
+	component.Extension
+	client *mockStorageClient
+}
+
+func (e *mockStorageExtension) GetClient(context.Context, component.Kind, config.ComponentID, string) (storage.Client, error) {
+	return e.client, nil
+}
+
+// mockHost serves the extensions.
+type mockHost struct {
+	component.Host
+	extensions map[config.ComponentID]component.Extension
+}
+
+func (h *mockHost) GetExtensions() map[config.ComponentID]component.Extension {
+	return h.extensions
+}
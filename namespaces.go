@@ -0,0 +1,123 @@
+package k8sobjectreceiver
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// namespaceWatcher watches the namespaces of a cluster and starts or stops
+// collection in the namespaces that match the selector of each object, as
+// namespaces are created, relabeled or deleted.
+type namespaceWatcher struct {
+	mu       sync.Mutex
+	informer cache.SharedIndexInformer
+	targets  []*namespaceTarget
+	// stop stops collection started by a target.
+	stop func(stopperChan chan struct{})
+}
+
+// namespaceTarget is an object collected in the namespaces matching a selector.
+type namespaceTarget struct {
+	selector labels.Selector
+	exclude  map[string]bool
+	// start starts collection in a namespace and returns its stopper channel.
+	start   func(namespace string) chan struct{}
+	running map[string]chan struct{}
+}
+
+func newNamespaceWatcher(client dynamic.Interface, stopperChan chan struct{}, stop func(chan struct{})) *namespaceWatcher {
+	informer := dynamicinformer.NewFilteredDynamicInformer(client, namespacesGVR, metav1.NamespaceAll, 0, cache.Indexers{}, nil).Informer()
+	w := &namespaceWatcher{
+		informer: informer,
+		stop:     stop,
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: w.sync,
+		UpdateFunc: func(_, obj interface{}) {
+			w.sync(obj)
+		},
+		DeleteFunc: w.delete,
+	})
+	go informer.Run(stopperChan)
+	return w
+}
+
+func newNamespaceTarget(object *K8sObjectsConfig, start func(namespace string) chan struct{}) (*namespaceTarget, error) {
+	selector, err := labels.Parse(object.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make(map[string]bool, len(object.ExcludeNamespaces))
+	for _, ns := range object.ExcludeNamespaces {
+		exclude[ns] = true
+	}
+	return &namespaceTarget{
+		selector: selector,
+		exclude:  exclude,
+		start:    start,
+		running:  make(map[string]chan struct{}),
+	}, nil
+}
+
+// add starts collecting a target in the matching namespaces known so far.
+func (w *namespaceWatcher) add(target *namespaceTarget) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.targets = append(w.targets, target)
+	for _, obj := range w.informer.GetStore().List() {
+		if ns, ok := obj.(metav1.Object); ok {
+			w.syncTarget(target, ns)
+		}
+	}
+}
+
+func (w *namespaceWatcher) sync(obj interface{}) {
+	ns, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, target := range w.targets {
+		w.syncTarget(target, ns)
+	}
+}
+
+func (w *namespaceWatcher) syncTarget(target *namespaceTarget, ns metav1.Object) {
+	name := ns.GetName()
+	matches := !target.exclude[name] && target.selector.Matches(labels.Set(ns.GetLabels()))
+	stopperChan, running := target.running[name]
+	switch {
+	case matches && !running:
+		target.running[name] = target.start(name)
+	case !matches && running:
+		w.stop(stopperChan)
+		delete(target.running, name)
+	}
+}
+
+func (w *namespaceWatcher) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	ns, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, target := range w.targets {
+		if stopperChan, running := target.running[ns.GetName()]; running {
+			w.stop(stopperChan)
+			delete(target.running, ns.GetName())
+		}
+	}
+}
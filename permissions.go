@@ -0,0 +1,201 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type PermissionCheck string
+
+const (
+	// PermissionCheckStrict stops the receiver when an object cannot be collected.
+	PermissionCheckStrict PermissionCheck = "strict"
+	// PermissionCheckLenient skips the objects that cannot be collected.
+	PermissionCheckLenient PermissionCheck = "lenient"
+	// PermissionCheckDisabled starts collection without checking permissions.
+	PermissionCheckDisabled PermissionCheck = "disabled"
+)
+
+var permissionCheckMap = map[PermissionCheck]bool{
+	PermissionCheckStrict:   true,
+	PermissionCheckLenient:  true,
+	PermissionCheckDisabled: true,
+}
+
+// permissionIssue is a reason an object cannot be collected.
+type permissionIssue struct {
+	object    string
+	resource  schema.GroupVersionResource
+	namespace string
+	verb      string
+	reason    string
+}
+
+func (i permissionIssue) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("object", i.object)
+	enc.AddString("resource", i.resource.String())
+	if i.namespace != "" {
+		enc.AddString("namespace", i.namespace)
+	}
+	enc.AddString("verb", i.verb)
+	enc.AddString("reason", i.reason)
+	return nil
+}
+
+func (i permissionIssue) String() string {
+	scope := "all namespaces"
+	if i.namespace != "" {
+		scope = "namespace " + i.namespace
+	}
+	return fmt.Sprintf("%v %v in %v: %v", i.verb, i.resource.GroupResource(), scope, i.reason)
+}
+
+type permissionIssues []permissionIssue
+
+func (issues permissionIssues) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, issue := range issues {
+		if err := enc.AppendObject(issue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (issues permissionIssues) Error() string {
+	descriptions := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		descriptions = append(descriptions, issue.String())
+	}
+	return "missing permissions: " + strings.Join(descriptions, "; ")
+}
+
+// targetNamespaces returns the namespaces an object collects a resource
+// from, an empty namespace means all namespaces. Cluster-scoped resources
+// are always collected from all namespaces.
+func (c *K8sObjectsConfig) targetNamespaces(res apiResource) []string {
+	if len(c.Namespaces) == 0 || !res.resource.Namespaced {
+		return []string{metav1.NamespaceAll}
+	}
+	namespaces := make([]string, 0, len(c.Namespaces))
+	for _, ns := range c.Namespaces {
+		if !c.isExcludedNamespace(ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// selectsNamespaces returns whether the namespaces an object collects a
+// resource from are selected by its namespace selector. Cluster-scoped
+// resources are not selected by namespace.
+func (c *K8sObjectsConfig) selectsNamespaces(res apiResource) bool {
+	return c.NamespaceSelector != "" && res.resource.Namespaced
+}
+
+// checkPermissions returns the namespaces an object can be collected from
+// and the issues preventing collection from the other namespaces. The
+// verbs supported by the resource are checked against the mode of the
+// object, and self subject access reviews verify the permissions of the
+// receiver. Objects with a namespace selector are checked for the
+// permissions to watch namespaces and to collect the resource in all
+// namespaces.
+func (kr *k8sobjectreceiver) checkPermissions(ctx context.Context, cluster *cluster, object *K8sObjectsConfig, res apiResource) ([]string, permissionIssues) {
+	namespaces := object.targetNamespaces(res)
+	if kr.permissionCheck == PermissionCheckDisabled {
+		return namespaces, nil
+	}
+
+	var issues permissionIssues
+	verbs := requiredVerbs(object.Mode)
+	for _, verb := range verbs {
+		// Resources with an unknown verb list, e.g. from an offline
+		// resource list, are checked with access reviews only.
+		if len(res.resource.Verbs) > 0 && !res.supports(verb) {
+			issues = append(issues, permissionIssue{
+				object:   object.reference(),
+				resource: res.gvr,
+				verb:     verb,
+				reason:   "verb not supported by the resource",
+			})
+		}
+	}
+	if len(issues) > 0 {
+		return nil, issues
+	}
+	if cluster.reviews == nil {
+		return namespaces, nil
+	}
+
+	review := func(namespace, verb string, gvr schema.GroupVersionResource) *permissionIssue {
+		result, err := cluster.reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      verb,
+					Group:     gvr.Group,
+					Version:   gvr.Version,
+					Resource:  gvr.Resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		issue := &permissionIssue{
+			object:    object.reference(),
+			resource:  gvr,
+			namespace: namespace,
+			verb:      verb,
+		}
+		switch {
+		case err != nil:
+			issue.reason = "access review failed: " + err.Error()
+		case !result.Status.Allowed:
+			issue.reason = "denied"
+			if result.Status.Reason != "" {
+				issue.reason += ": " + result.Status.Reason
+			}
+		default:
+			return nil
+		}
+		return issue
+	}
+
+	if object.selectsNamespaces(res) {
+		// The selected namespaces change while the object is collected,
+		// the resource is checked in all namespaces like the RBAC rules
+		// generated for it.
+		for _, verb := range []string{"list", "watch"} {
+			if issue := review(metav1.NamespaceAll, verb, namespacesGVR); issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+		for _, verb := range verbs {
+			if issue := review(metav1.NamespaceAll, verb, res.gvr); issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+		if len(issues) > 0 {
+			return nil, issues
+		}
+		return namespaces, nil
+	}
+
+	allowed := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		denied := false
+		for _, verb := range verbs {
+			if issue := review(ns, verb, res.gvr); issue != nil {
+				issues = append(issues, *issue)
+				denied = true
+			}
+		}
+		if !denied {
+			allowed = append(allowed, ns)
+		}
+	}
+	return allowed, issues
+}
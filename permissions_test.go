@@ -0,0 +1,162 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	typedauthorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	t.Parallel()
+
+	pods := apiResource{
+		gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		resource: metav1.APIResource{
+			Name:       "pods",
+			Namespaced: true,
+			Verbs:      []string{"list", "watch"},
+		},
+	}
+	nodes := apiResource{
+		gvr: schema.GroupVersionResource{Version: "v1", Resource: "nodes"},
+		resource: metav1.APIResource{
+			Name:  "nodes",
+			Verbs: []string{"list", "watch"},
+		},
+	}
+	challenges := apiResource{
+		gvr: schema.GroupVersionResource{Group: "acme.cert-manager.io", Version: "v1", Resource: "challenges"},
+		resource: metav1.APIResource{
+			Name:  "challenges",
+			Verbs: []string{"get", "list"},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		permissionCheck    PermissionCheck
+		object             *K8sObjectsConfig
+		res                apiResource
+		denied             []string
+		expectedNamespaces []string
+		expectedIssues     []string
+	}{
+		{
+			name:               "allowed in all namespaces",
+			object:             &K8sObjectsConfig{Name: "pods", Mode: WatchMode},
+			res:                pods,
+			expectedNamespaces: []string{""},
+		},
+		{
+			name:               "denied in one namespace",
+			object:             &K8sObjectsConfig{Name: "pods", Mode: PullMode, Namespaces: []string{"default", "kube-system", "excluded"}, ExcludeNamespaces: []string{"excluded"}},
+			res:                pods,
+			denied:             []string{"kube-system/pods/list"},
+			expectedNamespaces: []string{"default"},
+			expectedIssues:     []string{"list pods in namespace kube-system: denied: RBAC: access denied"},
+		},
+		{
+			name:           "namespace selector without access to the resource",
+			object:         &K8sObjectsConfig{Name: "pods", Mode: WatchMode, NamespaceSelector: "tenant=true"},
+			res:            pods,
+			denied:         []string{"/pods/watch"},
+			expectedIssues: []string{"watch pods in all namespaces: denied: RBAC: access denied"},
+		},
+		{
+			name:               "cluster-scoped in all namespaces",
+			object:             &K8sObjectsConfig{Name: "*", Mode: PullMode, Namespaces: []string{"default"}},
+			res:                nodes,
+			denied:             []string{"default/nodes/list"},
+			expectedNamespaces: []string{""},
+		},
+		{
+			name:               "cluster-scoped with namespace selector",
+			object:             &K8sObjectsConfig{Name: "*", Mode: PullMode, NamespaceSelector: "tenant=true"},
+			res:                nodes,
+			denied:             []string{"/namespaces/watch"},
+			expectedNamespaces: []string{""},
+		},
+		{
+			name:               "watch without list",
+			object:             &K8sObjectsConfig{Name: "pods", Mode: WatchMode},
+			res:                pods,
+			denied:             []string{"/pods/list"},
+			expectedNamespaces: []string{},
+			expectedIssues:     []string{"list pods in all namespaces: denied: RBAC: access denied"},
+		},
+		{
+			name:           "verb not supported",
+			object:         &K8sObjectsConfig{Name: "challenges.acme.cert-manager.io", Mode: WatchMode},
+			res:            challenges,
+			expectedIssues: []string{"watch challenges.acme.cert-manager.io in all namespaces: verb not supported by the resource"},
+		},
+		{
+			name:           "namespace selector",
+			object:         &K8sObjectsConfig{Name: "pods", Mode: PullMode, NamespaceSelector: "tenant=true"},
+			res:            pods,
+			denied:         []string{"/namespaces/watch"},
+			expectedIssues: []string{"watch namespaces in all namespaces: denied: RBAC: access denied"},
+		},
+		{
+			name:               "disabled",
+			permissionCheck:    PermissionCheckDisabled,
+			object:             &K8sObjectsConfig{Name: "challenges.acme.cert-manager.io", Mode: WatchMode},
+			res:                challenges,
+			expectedNamespaces: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rCfg := createDefaultConfig().(*Config)
+			rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+			rCfg.makeAccessReviewClient = newMockAccessReviewClient(tt.denied...).getMockAccessReviewClient
+			if tt.permissionCheck != "" {
+				rCfg.PermissionCheck = tt.permissionCheck
+			}
+			r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumertest.NewNop())
+			require.NoError(t, err)
+			kr := r.(*k8sobjectreceiver)
+
+			namespaces, issues := kr.checkPermissions(context.Background(), kr.clusters[0], tt.object, tt.res)
+			assert.Equal(t, tt.expectedNamespaces, namespaces)
+			var descriptions []string
+			for _, issue := range issues {
+				descriptions = append(descriptions, issue.String())
+			}
+			assert.Equal(t, tt.expectedIssues, descriptions)
+		})
+	}
+}
+
+func TestAccessReviewClientReused(t *testing.T) {
+	t.Parallel()
+
+	var created int
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.makeAccessReviewClient = func() (typedauthorizationv1.SelfSubjectAccessReviewInterface, error) {
+		created++
+		return newMockAccessReviewClient().getMockAccessReviewClient()
+	}
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumertest.NewNop())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	pods := apiResource{
+		gvr:      schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		resource: metav1.APIResource{Name: "pods", Namespaced: true},
+	}
+	for i := 0; i < 2; i++ {
+		namespaces, issues := kr.checkPermissions(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: PullMode}, pods)
+		assert.Equal(t, []string{""}, namespaces)
+		assert.Empty(t, issues)
+	}
+	assert.Equal(t, 1, created)
+}
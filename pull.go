@@ -0,0 +1,303 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxListRestarts is how many times a paginated list is restarted when its
+// continue token expires before the pull fails.
+const maxListRestarts = 3
+
+func (kr *k8sobjectreceiver) startPull(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, namespace string, stopperChan chan struct{}, resource objectResource) {
+	schedule := newPullSchedule(config, cluster.name+"/"+gvr.String()+"/"+namespace)
+	state := &pullState{namespace: namespace}
+	if config.PullEmit == PullEmitChanges {
+		state.changes = newPullChanges()
+	}
+	mutators := []tag.Mutator{
+		tag.Upsert(tagReceiverKey, cluster.settings.receiverID.String()),
+		tag.Upsert(tagClusterKey, cluster.name),
+		tag.Upsert(tagResourceKey, gvr.String()),
+		tag.Upsert(tagNamespaceKey, namespace),
+	}
+	recordInterval := func() {
+		if schedule.adaptive() {
+			_ = stats.RecordWithTags(ctx, mutators, mPullInterval.M(schedule.interval.Seconds()))
+		}
+	}
+	recordInterval()
+
+	timer := time.NewTimer(time.Until(schedule.first(time.Now())))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			pulled := state.pulled
+			changed, err := kr.pull(ctx, cluster, config, gvr, resource, state)
+			if errors.Is(err, context.DeadlineExceeded) && config.Timeout > 0 {
+				kr.setting.Logger.Error("pulling object timed out", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("namespace", namespace), zap.Duration("timeout", config.Timeout))
+			} else if err != nil {
+				kr.setting.Logger.Error("error in pulling object", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+			} else if pulled {
+				// The interval only adapts to the changes between pulls.
+				schedule.adapt(changed)
+				recordInterval()
+			}
+			next := schedule.after(time.Now())
+			if next.IsZero() {
+				kr.setting.Logger.Warn("schedule has no next pull, stopping", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("schedule", config.Schedule))
+				return
+			}
+			timer.Reset(time.Until(next))
+		case <-stopperChan:
+			return
+		}
+	}
+}
+
+// pull lists the objects of a resource and sends them to the consumer.
+// With a page size, the list is paginated and every page is sent as soon
+// as it is received. A list whose continue token expires is restarted
+// from the beginning, so objects of the earlier pages are sent again.
+// With changes, only the objects that changed since the previous pull are
+// sent, and deletions are sent once the list is complete. The records of a
+// list are stamped with the id of its snapshot, which can be marked by
+// begin and end records. It returns whether the objects changed since the
+// previous complete pull.
+func (kr *k8sobjectreceiver) pull(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, resource objectResource, state *pullState) (bool, error) {
+	// The timeout only applies to the requests, the records of a pull that
+	// times out while they are consumed are not dropped.
+	listCtx := ctx
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		listCtx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	opts := metav1.ListOptions{
+		FieldSelector: config.fieldSelector(),
+		LabelSelector: config.LabelSelector,
+		Limit:         config.PageSize,
+		// Resource versions only apply to the first page, later pages
+		// are consistent with it through the continue token.
+		ResourceVersion:      config.ResourceVersion,
+		ResourceVersionMatch: config.ResourceVersionMatch,
+	}
+	changes := state.changes
+	var seen map[types.UID]pulledObject
+	if changes != nil {
+		seen = make(map[types.UID]pulledObject)
+	}
+	fingerprint := fnv.New64a()
+	snap := newSnapshot()
+	restarts := 0
+	for {
+		objects, columns, err := kr.list(listCtx, cluster, resource, opts)
+		if err != nil {
+			if opts.Continue != "" && isExpired(err) && restarts < maxListRestarts {
+				restarts++
+				kr.setting.Logger.Warn("continue token expired, restarting list", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Int("restarts", restarts))
+				opts.Continue = ""
+				opts.ResourceVersion = config.ResourceVersion
+				opts.ResourceVersionMatch = config.ResourceVersionMatch
+				fingerprint.Reset()
+				snap = newSnapshot()
+				continue
+			}
+			return false, err
+		}
+
+		for _, item := range objects.Items {
+			_, _ = fingerprint.Write([]byte(string(item.GetUID()) + "/" + item.GetResourceVersion() + "\n"))
+		}
+
+		// The first page is requested without a continue token.
+		if opts.Continue == "" {
+			snap.resourceVersion = objects.GetResourceVersion()
+			if config.SnapshotMarkers {
+				kr.consumer.ConsumeLogs(ctx, snapshotMarkerToLogData(snap, snapshotBegin, expectedCount(objects), objects, state.namespace, cluster.name))
+			}
+		}
+		snap.count += int64(len(objects.Items))
+
+		if changes == nil {
+			if len(objects.Items) > 0 {
+				kr.consumer.ConsumeLogs(ctx, listToLogData(objects, nil, columns, snap, cluster.name))
+			}
+		} else if changed, changeTypes, changedColumns := changes.diff(objects, columns, seen); len(changed.Items) > 0 {
+			kr.consumer.ConsumeLogs(ctx, listToLogData(changed, changeTypes, changedColumns, snap, cluster.name))
+		}
+
+		opts.Continue = objects.GetContinue()
+		if opts.Continue == "" {
+			if changes != nil {
+				if deleted, changeTypes := changes.complete(objects, seen); len(deleted.Items) > 0 {
+					kr.consumer.ConsumeLogs(ctx, listToLogData(deleted, changeTypes, nil, snap, cluster.name))
+				}
+			}
+			if config.SnapshotMarkers {
+				kr.consumer.ConsumeLogs(ctx, snapshotMarkerToLogData(snap, snapshotEnd, snap.count, objects, state.namespace, cluster.name))
+			}
+			return state.complete(fingerprint.Sum64()), nil
+		}
+		opts.ResourceVersion = ""
+		opts.ResourceVersionMatch = ""
+	}
+}
+
+// list requests a page of objects once a worker is available. Pages of
+// tables also return the printer columns of every object.
+func (kr *k8sobjectreceiver) list(ctx context.Context, cluster *cluster, resource objectResource, opts metav1.ListOptions) (*unstructured.UnstructuredList, []map[string]interface{}, error) {
+	if kr.workers != nil {
+		select {
+		case kr.workers <- struct{}{}:
+			defer func() { <-kr.workers }()
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	if cluster.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cluster.config.Timeout)
+		defer cancel()
+	}
+	if table, ok := resource.(*tableResource); ok {
+		return table.listTable(ctx, opts)
+	}
+	list, err := resource.List(ctx, opts)
+	return list, nil, err
+}
+
+// expectedCount returns the number of objects of a list from its first
+// page, or -1 when it is unknown.
+func expectedCount(firstPage *unstructured.UnstructuredList) int64 {
+	count := int64(len(firstPage.Items))
+	if firstPage.GetContinue() == "" {
+		return count
+	}
+	if remaining := firstPage.GetRemainingItemCount(); remaining != nil {
+		return count + *remaining
+	}
+	return -1
+}
+
+// pullState is kept between the pulls of a collection.
+type pullState struct {
+	// namespace is the namespace of the collection, empty for all namespaces.
+	namespace string
+	// changes is set when only the changed objects are sent.
+	changes *pullChanges
+	// fingerprint hashes the uids and resource versions of the objects of
+	// the last complete pull.
+	fingerprint uint64
+	pulled      bool
+}
+
+// complete records the fingerprint of a complete pull and returns whether
+// it differs from the previous one. The first pull is always a change.
+func (s *pullState) complete(fingerprint uint64) bool {
+	changed := !s.pulled || fingerprint != s.fingerprint
+	s.fingerprint = fingerprint
+	s.pulled = true
+	return changed
+}
+
+// pulledObject is what is remembered of an object between pulls.
+type pulledObject struct {
+	apiVersion      string
+	kind            string
+	name            string
+	namespace       string
+	resourceVersion string
+}
+
+// pullChanges remembers the objects of the last complete pull, by uid, to
+// find the objects that changed since.
+type pullChanges struct {
+	objects map[types.UID]pulledObject
+}
+
+func newPullChanges() *pullChanges {
+	return &pullChanges{objects: make(map[types.UID]pulledObject)}
+}
+
+// diff returns the objects of a page that were added or modified since the
+// last complete pull, with their printer columns when the page is a table,
+// and records them in seen. Objects already seen in this pull, e.g. when a
+// list is restarted, are not returned again.
+func (c *pullChanges) diff(page *unstructured.UnstructuredList, columns []map[string]interface{}, seen map[types.UID]pulledObject) (*unstructured.UnstructuredList, []watch.EventType, []map[string]interface{}) {
+	changed := &unstructured.UnstructuredList{Object: page.Object}
+	var changeTypes []watch.EventType
+	var changedColumns []map[string]interface{}
+	for i, item := range page.Items {
+		uid := item.GetUID()
+		prev, ok := seen[uid]
+		if !ok {
+			prev, ok = c.objects[uid]
+		}
+		seen[uid] = pulledObject{
+			apiVersion:      item.GetAPIVersion(),
+			kind:            item.GetKind(),
+			name:            item.GetName(),
+			namespace:       item.GetNamespace(),
+			resourceVersion: item.GetResourceVersion(),
+		}
+		switch {
+		case !ok:
+			changeTypes = append(changeTypes, watch.Added)
+		case prev.resourceVersion != item.GetResourceVersion():
+			changeTypes = append(changeTypes, watch.Modified)
+		default:
+			continue
+		}
+		changed.Items = append(changed.Items, item)
+		if columns != nil {
+			changedColumns = append(changedColumns, columns[i])
+		}
+	}
+	return changed, changeTypes, changedColumns
+}
+
+// complete replaces the remembered objects with the objects seen in a
+// complete pull and returns deletion records for the objects that
+// disappeared. The records only hold the metadata that was remembered.
+func (c *pullChanges) complete(last *unstructured.UnstructuredList, seen map[types.UID]pulledObject) (*unstructured.UnstructuredList, []watch.EventType) {
+	deleted := &unstructured.UnstructuredList{Object: last.Object}
+	var changeTypes []watch.EventType
+	for uid, object := range c.objects {
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		item := unstructured.Unstructured{Object: map[string]interface{}{}}
+		item.SetAPIVersion(object.apiVersion)
+		item.SetKind(object.kind)
+		item.SetName(object.name)
+		if object.namespace != "" {
+			item.SetNamespace(object.namespace)
+		}
+		item.SetUID(uid)
+		item.SetResourceVersion(object.resourceVersion)
+		deleted.Items = append(deleted.Items, item)
+		changeTypes = append(changeTypes, watch.Deleted)
+	}
+	sort.Slice(deleted.Items, func(i, j int) bool {
+		if deleted.Items[i].GetNamespace() != deleted.Items[j].GetNamespace() {
+			return deleted.Items[i].GetNamespace() < deleted.Items[j].GetNamespace()
+		}
+		return deleted.Items[i].GetName() < deleted.Items[j].GetName()
+	})
+	c.objects = seen
+	return deleted, changeTypes
+}
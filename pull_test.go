@@ -0,0 +1,437 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// pagedResource serves lists of pods in pages of the requested limit.
+// The continue token of expiredPage expires the first time it is used.
+type pagedResource struct {
+	dynamic.ResourceInterface
+	t           *testing.T
+	total       int
+	expiredPage int
+	expired     bool
+	options     []metav1.ListOptions
+}
+
+func (r *pagedResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.options = append(r.options, opts)
+
+	start := 0
+	if opts.Continue != "" {
+		var err error
+		start, err = strconv.Atoi(opts.Continue)
+		require.NoError(r.t, err)
+		if start/int(opts.Limit) == r.expiredPage && !r.expired {
+			r.expired = true
+			return nil, apierrors.NewResourceExpired("continue token expired")
+		}
+	}
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+	list.SetResourceVersion("1000")
+	end := start + int(opts.Limit)
+	if end >= r.total {
+		end = r.total
+	} else {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	for i := start; i < end; i++ {
+		list.Items = append(list.Items, *generatePod("pod"+strconv.Itoa(i), "default", map[string]interface{}{}))
+	}
+	return list, nil
+}
+
+func TestPullPaginated(t *testing.T) {
+	t.Parallel()
+
+	resource := &pagedResource{t: t, total: 5, expiredPage: -1}
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, PageSize: 2}
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{})
+	require.NoError(t, err)
+
+	require.Len(t, resource.options, 3)
+	for _, opts := range resource.options {
+		assert.Equal(t, int64(2), opts.Limit)
+	}
+	require.Len(t, consumer.Logs, 3)
+	assert.Equal(t, 5, consumer.Count)
+	assert.Equal(t, 1, consumer.Logs[2].LogRecordCount())
+}
+
+func TestPullRestartsExpiredList(t *testing.T) {
+	t.Parallel()
+
+	resource := &pagedResource{t: t, total: 5, expiredPage: 1}
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, PageSize: 2}
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{})
+	require.NoError(t, err)
+
+	// The first page is sent again after the list is restarted.
+	assert.Len(t, resource.options, 5)
+	assert.Equal(t, 7, consumer.Count)
+}
+
+func TestPullResourceVersionMatch(t *testing.T) {
+	t.Parallel()
+
+	resource := &pagedResource{t: t, total: 3, expiredPage: -1}
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:                 "pods",
+			Mode:                 PullMode,
+			PageSize:             2,
+			ResourceVersionMatch: metav1.ResourceVersionMatchNotOlderThan,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	_, err = kr.pull(context.Background(), kr.clusters[0], rCfg.Objects[0], podsGVR, resource, &pullState{})
+	require.NoError(t, err)
+
+	require.Len(t, resource.options, 2)
+	assert.Equal(t, "0", resource.options[0].ResourceVersion)
+	assert.Equal(t, metav1.ResourceVersionMatchNotOlderThan, resource.options[0].ResourceVersionMatch)
+	// The resource version cannot be combined with a continue token.
+	assert.Equal(t, "2", resource.options[1].Continue)
+	assert.Empty(t, resource.options[1].ResourceVersion)
+	assert.Empty(t, resource.options[1].ResourceVersionMatch)
+
+	attrs := consumer.Logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	listResourceVersion, ok := attrs.Get("k8s.list.resource_version")
+	require.True(t, ok)
+	assert.Equal(t, "1000", listResourceVersion.StringVal())
+	// The object resource version of pulled records is the one of the list.
+	objectResourceVersion, ok := attrs.Get("k8s.object.resource_version")
+	require.True(t, ok)
+	assert.Equal(t, "1000", objectResourceVersion.StringVal())
+}
+
+// podsResource serves its pods in a single list.
+type podsResource struct {
+	dynamic.ResourceInterface
+	pods []*unstructured.Unstructured
+}
+
+func (r *podsResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+	for _, pod := range r.pods {
+		list.Items = append(list.Items, *pod)
+	}
+	return list, nil
+}
+
+func generateVersionedPod(name, resourceVersion string) *unstructured.Unstructured {
+	pod := generatePod(name, "default", map[string]interface{}{})
+	pod.SetUID(types.UID(name + "-uid"))
+	pod.SetResourceVersion(resourceVersion)
+	return pod
+}
+
+func TestPullChanges(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, PullEmit: PullEmitChanges}
+	state := &pullState{changes: newPullChanges()}
+	pull := func(pods ...*unstructured.Unstructured) map[string]string {
+		consumer.Logs = nil
+		_, err := kr.pull(context.Background(), kr.clusters[0], object, podsGVR, &podsResource{pods: pods}, state)
+		require.NoError(t, err)
+
+		changed := make(map[string]string)
+		for _, logs := range consumer.Logs {
+			records := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < records.Len(); i++ {
+				name, ok := records.At(i).Attributes().Get("k8s.object.name")
+				require.True(t, ok)
+				changeType, ok := records.At(i).Attributes().Get("k8s.object.change_type")
+				require.True(t, ok)
+				changed[name.StringVal()] = changeType.StringVal()
+			}
+		}
+		return changed
+	}
+
+	assert.Equal(t, map[string]string{"pod1": "ADDED", "pod2": "ADDED"}, pull(generateVersionedPod("pod1", "1"), generateVersionedPod("pod2", "2")))
+	assert.Empty(t, pull(generateVersionedPod("pod1", "1"), generateVersionedPod("pod2", "2")))
+	assert.Equal(t, map[string]string{"pod2": "MODIFIED", "pod3": "ADDED"}, pull(generateVersionedPod("pod1", "1"), generateVersionedPod("pod2", "4"), generateVersionedPod("pod3", "3")))
+	assert.Equal(t, map[string]string{"pod1": "DELETED", "pod3": "DELETED"}, pull(generateVersionedPod("pod2", "4")))
+
+	// Deletion records hold the remembered metadata.
+	pull(generateVersionedPod("pod2", "4"), generateVersionedPod("pod5", "5"))
+	pull(generateVersionedPod("pod2", "4"))
+	require.Len(t, consumer.Logs, 1)
+	body := consumer.Logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().MapVal().AsRaw()
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pods",
+		"metadata": map[string]interface{}{
+			"name":            "pod5",
+			"namespace":       "default",
+			"uid":             "pod5-uid",
+			"resourceVersion": "5",
+		},
+	}, body)
+}
+
+func TestPullDetectsChanges(t *testing.T) {
+	t.Parallel()
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode}
+	state := &pullState{}
+	pull := func(pods ...*unstructured.Unstructured) bool {
+		changed, err := kr.pull(context.Background(), kr.clusters[0], object, podsGVR, &podsResource{pods: pods}, state)
+		require.NoError(t, err)
+		return changed
+	}
+
+	assert.True(t, pull(generateVersionedPod("pod1", "1")), "first pull")
+	assert.False(t, pull(generateVersionedPod("pod1", "1")))
+	assert.True(t, pull(generateVersionedPod("pod1", "2")), "modified")
+	assert.True(t, pull(generateVersionedPod("pod1", "2"), generateVersionedPod("pod2", "3")), "added")
+	assert.True(t, pull(generateVersionedPod("pod2", "3")), "deleted")
+	assert.False(t, pull(generateVersionedPod("pod2", "3")))
+}
+
+func TestPullSnapshotMarkers(t *testing.T) {
+	t.Parallel()
+
+	resource := &pagedResource{t: t, total: 5, expiredPage: 1}
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, PageSize: 2, SnapshotMarkers: true}
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{namespace: "default"})
+	require.NoError(t, err)
+
+	type record struct {
+		marker string
+		id     string
+		count  int64
+	}
+	var records []record
+	for _, logs := range consumer.Logs {
+		lrs := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < lrs.Len(); i++ {
+			attrs := lrs.At(i).Attributes()
+			id, ok := attrs.Get("k8s.snapshot.id")
+			require.True(t, ok)
+			rv, ok := attrs.Get("k8s.snapshot.resource_version")
+			require.True(t, ok)
+			assert.Equal(t, "1000", rv.StringVal())
+
+			r := record{id: id.StringVal(), count: -1}
+			if marker, ok := attrs.Get("k8s.snapshot.marker"); ok {
+				r.marker = marker.StringVal()
+				if count, ok := attrs.Get("k8s.snapshot.object_count"); ok {
+					r.count = count.IntVal()
+				}
+			}
+			records = append(records, r)
+		}
+	}
+
+	// The first page is followed by an expired continue token, the
+	// restarted list is a new snapshot.
+	require.Len(t, records, 10)
+	first, second := records[0].id, records[3].id
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, []record{
+		{marker: "SNAPSHOT_BEGIN", id: first, count: -1},
+		{id: first, count: -1},
+		{id: first, count: -1},
+		{marker: "SNAPSHOT_BEGIN", id: second, count: -1},
+		{id: second, count: -1},
+		{id: second, count: -1},
+		{id: second, count: -1},
+		{id: second, count: -1},
+		{id: second, count: -1},
+		{marker: "SNAPSHOT_END", id: second, count: 5},
+	}, records)
+}
+
+func TestPullSnapshotMarkersEmptyList(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.ClusterName = "production"
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, SnapshotMarkers: true}
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, &podsResource{}, &pullState{namespace: "default"})
+	require.NoError(t, err)
+
+	require.Len(t, consumer.Logs, 2)
+	for i, marker := range []string{"SNAPSHOT_BEGIN", "SNAPSHOT_END"} {
+		rl := consumer.Logs[i].ResourceLogs().At(0)
+		assert.Equal(t, map[string]interface{}{
+			"k8s.object.kind":        "Pod",
+			"k8s.object.api_version": "v1",
+			"k8s.cluster.name":       "production",
+		}, rl.Resource().Attributes().AsRaw())
+
+		lr := rl.ScopeLogs().At(0).LogRecords().At(0)
+		assert.Equal(t, map[string]interface{}{"type": marker}, lr.Body().MapVal().AsRaw())
+		attrs := lr.Attributes().AsRaw()
+		assert.Equal(t, marker, attrs["k8s.snapshot.marker"])
+		assert.Equal(t, int64(0), attrs["k8s.snapshot.object_count"])
+		assert.Equal(t, "default", attrs["k8s.namespace.name"])
+	}
+}
+
+// blockingResource blocks lists until they are released or cancelled, and
+// records the number of lists in flight.
+type blockingResource struct {
+	dynamic.ResourceInterface
+	release     chan struct{}
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *blockingResource) List(ctx context.Context, _ metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight--
+		r.mu.Unlock()
+	}()
+
+	select {
+	case <-r.release:
+		return &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestPullWorkers(t *testing.T) {
+	t.Parallel()
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.Workers = 2
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	require.NoError(t, rCfg.Validate())
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	resource := &blockingResource{release: make(chan struct{})}
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{})
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Release the lists one by one once all workers are busy.
+	for pending := 5; pending > 0; pending-- {
+		busy := rCfg.Workers
+		if pending < busy {
+			busy = pending
+		}
+		require.Eventually(t, func() bool {
+			resource.mu.Lock()
+			defer resource.mu.Unlock()
+			return resource.inFlight == busy
+		}, time.Second, time.Millisecond)
+		resource.release <- struct{}{}
+	}
+	wg.Wait()
+	assert.Equal(t, 2, resource.maxInFlight)
+}
+
+func TestPullTimeout(t *testing.T) {
+	t.Parallel()
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.Workers = 1
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	resource := &blockingResource{release: make(chan struct{})}
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The worker of the cancelled list is available again.
+	go func() { resource.release <- struct{}{} }()
+	_, err = kr.pull(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: PullMode}, podsGVR, resource, &pullState{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,142 @@
+package k8sobjectreceiver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RBACRules are the RBAC rules the receiver needs to collect the objects
+// configured for a cluster.
+type RBACRules struct {
+	// ClusterRules are needed in all namespaces or for cluster scoped resources.
+	ClusterRules []rbacv1.PolicyRule
+	// NamespaceRules are needed in single namespaces, keyed by namespace.
+	NamespaceRules map[string][]rbacv1.PolicyRule
+}
+
+// RBACRules returns the rules needed to collect the objects of a cluster,
+// an empty name selects the top level objects. Object references are
+// resolved against the resources, or through API discovery of the cluster
+// when resources is nil.
+func (c *Config) RBACRules(clusterName string, resources []*metav1.APIResourceList) (*RBACRules, error) {
+	var cluster *ClusterConfig
+	for _, cl := range c.clusters() {
+		if cl.Name == clusterName || len(c.Clusters) == 0 && clusterName == "" {
+			cluster = cl
+			break
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %v not found", clusterName)
+	}
+
+	var discovered *discoveredResources
+	if resources == nil {
+		var err error
+		if discovered, err = cluster.getValidObjects(clientSettings{receiverID: c.ID()}); err != nil {
+			return nil, err
+		}
+	} else {
+		apiResources, err := newAPIResources(resources)
+		if err != nil {
+			return nil, err
+		}
+		discovered = &discoveredResources{resources: apiResources}
+	}
+
+	clusterRules := newRuleSet()
+	namespaceRules := make(map[string]ruleSet)
+	for _, object := range cluster.Objects {
+		resolved, err := discovered.resolve(object)
+		if err != nil {
+			return nil, err
+		}
+		verbs := requiredVerbs(object.Mode)
+		if object.NamespaceSelector != "" {
+			clusterRules.add("", "namespaces", "list", "watch")
+		}
+		for _, res := range resolved {
+			namespaces := object.targetNamespaces(res)
+			if object.selectsNamespaces(res) {
+				namespaces = []string{metav1.NamespaceAll}
+			}
+			for _, ns := range namespaces {
+				if ns == metav1.NamespaceAll {
+					clusterRules.add(res.gvr.Group, res.gvr.Resource, verbs...)
+					continue
+				}
+				if namespaceRules[ns] == nil {
+					namespaceRules[ns] = newRuleSet()
+				}
+				namespaceRules[ns].add(res.gvr.Group, res.gvr.Resource, verbs...)
+			}
+		}
+	}
+
+	rules := &RBACRules{
+		ClusterRules:   clusterRules.policyRules(),
+		NamespaceRules: make(map[string][]rbacv1.PolicyRule, len(namespaceRules)),
+	}
+	for ns, set := range namespaceRules {
+		rules.NamespaceRules[ns] = set.policyRules()
+	}
+	return rules, nil
+}
+
+// ruleSet collects the verbs needed per group and resource.
+type ruleSet map[string]map[string]map[string]bool
+
+func newRuleSet() ruleSet {
+	return make(ruleSet)
+}
+
+func (s ruleSet) add(group, resource string, verbs ...string) {
+	if s[group] == nil {
+		s[group] = make(map[string]map[string]bool)
+	}
+	if s[group][resource] == nil {
+		s[group][resource] = make(map[string]bool)
+	}
+	for _, verb := range verbs {
+		s[group][resource][verb] = true
+	}
+}
+
+// policyRules returns one rule per group and set of verbs, sorted so that
+// the output is stable.
+func (s ruleSet) policyRules() []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	for group, resources := range s {
+		byVerbs := make(map[string]*rbacv1.PolicyRule)
+		for resource, verbSet := range resources {
+			verbs := make([]string, 0, len(verbSet))
+			for verb := range verbSet {
+				verbs = append(verbs, verb)
+			}
+			sort.Strings(verbs)
+			key := strings.Join(verbs, ",")
+			if byVerbs[key] == nil {
+				byVerbs[key] = &rbacv1.PolicyRule{
+					APIGroups: []string{group},
+					Verbs:     verbs,
+				}
+			}
+			byVerbs[key].Resources = append(byVerbs[key].Resources, resource)
+		}
+		for _, rule := range byVerbs {
+			sort.Strings(rule.Resources)
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].APIGroups[0] != rules[j].APIGroups[0] {
+			return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+		}
+		return rules[i].Resources[0] < rules[j].Resources[0]
+	})
+	return rules
+}
@@ -0,0 +1,60 @@
+package k8sobjectreceiver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/service/servicetest"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRBACRules(t *testing.T) {
+	t.Parallel()
+	factories, err := componenttest.NopFactories()
+	require.NoError(t, err)
+
+	factory := NewFactory()
+	factories.Receivers[config.Type(typeStr)] = factory
+	cfg, err := servicetest.LoadConfig(filepath.Join("testdata", "rbac_config.yaml"), factories)
+	require.NoError(t, err)
+	rCfg := cfg.Receivers[config.NewComponentID(typeStr)].(*Config)
+	require.NoError(t, rCfg.Validate())
+
+	data, err := os.ReadFile(filepath.Join("testdata", "api_resources.yaml"))
+	require.NoError(t, err)
+	var resources []*metav1.APIResourceList
+	require.NoError(t, yaml.Unmarshal(data, &resources))
+
+	rules, err := rCfg.RBACRules("", resources)
+	require.NoError(t, err)
+	assert.Equal(t, &RBACRules{
+		ClusterRules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"events", "nodes"}, Verbs: []string{"list"}},
+			{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list", "watch"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"daemonsets"}, Verbs: []string{"list"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list", "watch"}},
+		},
+		NamespaceRules: map[string][]rbacv1.PolicyRule{
+			"default": {
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "watch"}},
+			},
+			"monitoring": {
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "watch"}},
+			},
+		},
+	}, rules)
+
+	_, err = rCfg.RBACRules("production", resources)
+	assert.EqualError(t, err, "cluster production not found")
+
+	rCfg.Objects = append(rCfg.Objects, &K8sObjectsConfig{Name: "certificates.cert-manager.io", Mode: PullMode})
+	_, err = rCfg.RBACRules("", resources)
+	assert.EqualError(t, err, "resource not found: certificates.cert-manager.io")
+}
@@ -2,146 +2,402 @@ package k8sobjectreceiver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/consumer"
 	"go.uber.org/zap"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
 )
 
+// discoveryBackoff controls how failed API discovery is retried.
+var discoveryBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+}
+
 type k8sobjectreceiver struct {
-	setting         component.ReceiverCreateSettings
-	objects         []*K8sObjectsConfig
+	id                config.ComponentID
+	setting           component.ReceiverCreateSettings
+	clusters          []*cluster
+	discoveryInterval time.Duration
+	permissionCheck   PermissionCheck
+	// workers holds a token for every list request in flight, it is nil
+	// when the number of requests is unlimited.
+	workers chan struct{}
+	// checkpoints holds the resource versions that watches resume from,
+	// they are persisted in the storage extension when one is configured.
+	checkpoints     *checkpoints
+	storageID       *config.ComponentID
+	host            component.Host
 	stopperChanList []chan struct{}
-	client          dynamic.Interface
+	stopped         bool
+	mu              sync.Mutex
 	consumer        consumer.Logs
 	startTime       time.Time
+	// collections tracks the pull and watch goroutines, Shutdown waits for
+	// them before persisting the checkpoints.
+	collections sync.WaitGroup
+}
+
+// cluster holds the client and objects of a single cluster.
+type cluster struct {
+	name    string
+	config  *ClusterConfig
+	objects []*K8sObjectsConfig
+	client  dynamic.Interface
+	// metadata is only created when an object only collects metadata.
+	metadata metadata.Interface
+	// table is only created when an object is pulled as a table.
+	table rest.Interface
+	// reviews is only created when permissions are checked, and is nil
+	// when its creation failed.
+	reviews authorizationv1.SelfSubjectAccessReviewInterface
+	// settings are used to create the discovery client.
+	settings clientSettings
+	// started holds the resources that are collected for each object. It
+	// is only accessed by the discovery goroutine.
+	started map[*K8sObjectsConfig]map[schema.GroupVersionResource]bool
+	// skipped holds the objects whose reference is ambiguous, discovery
+	// does not resolve them again.
+	skipped map[*K8sObjectsConfig]bool
+	// informers are shared by the objects watched with informers.
+	informers *sharedInformers
+	// namespaces is created when the first object with a namespace
+	// selector is started.
+	namespaces *namespaceWatcher
+}
+
+// pending returns whether discovery may start collecting more resources.
+// Objects with a pattern can match resources that are installed later.
+func (c *cluster) pending() bool {
+	for _, object := range c.objects {
+		if c.skipped[object] {
+			continue
+		}
+		if object.isPattern() || len(c.started[object]) == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func newReceiver(params component.ReceiverCreateSettings, config *Config, consumer consumer.Logs) (component.LogsReceiver, error) {
-	client, err := config.getDynamicClient()
-	if err != nil {
-		return nil, err
+	settings := clientSettings{
+		receiverID: config.ID(),
+		buildInfo:  params.BuildInfo,
+	}
+	var clusters []*cluster
+	for _, clusterConfig := range config.clusters() {
+		client, err := clusterConfig.getDynamicClient(settings)
+		if err != nil {
+			return nil, err
+		}
+		var metadataClient metadata.Interface
+		if clusterConfig.usesMetadata() {
+			if metadataClient, err = clusterConfig.getMetadataClient(settings); err != nil {
+				return nil, err
+			}
+		}
+		var tableClient rest.Interface
+		if clusterConfig.usesTables() {
+			if tableClient, err = clusterConfig.getTableClient(settings); err != nil {
+				return nil, err
+			}
+		}
+		// Permissions are not checked when the access review client cannot
+		// be created.
+		var reviews authorizationv1.SelfSubjectAccessReviewInterface
+		if config.PermissionCheck != PermissionCheckDisabled {
+			if reviews, err = clusterConfig.getAccessReviewClient(settings); err != nil {
+				params.Logger.Warn("unable to check permissions", zap.String("cluster", clusterConfig.Name), zap.Error(err))
+			}
+		}
+		clusters = append(clusters, &cluster{
+			name:      clusterConfig.Name,
+			config:    clusterConfig,
+			objects:   clusterConfig.Objects,
+			client:    client,
+			metadata:  metadataClient,
+			table:     tableClient,
+			reviews:   reviews,
+			settings:  settings,
+			started:   make(map[*K8sObjectsConfig]map[schema.GroupVersionResource]bool),
+			skipped:   make(map[*K8sObjectsConfig]bool),
+			informers: newSharedInformers(client),
+		})
+	}
+
+	var workers chan struct{}
+	if config.Workers > 0 {
+		workers = make(chan struct{}, config.Workers)
 	}
 
 	return &k8sobjectreceiver{
-		client:    client,
-		setting:   params,
-		consumer:  consumer,
-		objects:   config.Objects,
-		startTime: time.Now(),
+		id:                config.ID(),
+		clusters:          clusters,
+		discoveryInterval: config.DiscoveryInterval,
+		permissionCheck:   config.PermissionCheck,
+		workers:           workers,
+		checkpoints:       newCheckpoints(),
+		storageID:         config.Storage,
+		setting:           params,
+		consumer:          consumer,
+		startTime:         time.Now(),
 	}, nil
 }
 
 func (kr *k8sobjectreceiver) Start(ctx context.Context, host component.Host) error {
 	kr.setting.Logger.Info("Object Receiver started")
+	kr.host = host
+
+	if kr.storageID != nil {
+		client, err := getStorageClient(ctx, host, *kr.storageID, kr.id)
+		if err != nil {
+			return err
+		}
+		kr.checkpoints.client = client
+	}
 
-	for _, object := range kr.objects {
-		kr.start(ctx, object)
+	for _, cluster := range kr.clusters {
+		go kr.startDiscovery(ctx, cluster, kr.newStopperChan())
 	}
 	return nil
 }
 
-func (kr *k8sobjectreceiver) Shutdown(context.Context) error {
+func (kr *k8sobjectreceiver) Shutdown(ctx context.Context) error {
 	kr.setting.Logger.Info("Object Receiver stopped")
+	kr.mu.Lock()
+	kr.stopped = true
 	for _, stopperChan := range kr.stopperChanList {
 		close(stopperChan)
 	}
-	return nil
-}
+	kr.stopperChanList = nil
+	kr.mu.Unlock()
 
-func (kr *k8sobjectreceiver) start(ctx context.Context, object *K8sObjectsConfig) {
-	resource := kr.client.Resource(*object.gvr)
+	// Stopping watches checkpoint their last resource version.
+	kr.collections.Wait()
+	if kr.checkpoints.client == nil {
+		return nil
+	}
+	if err := kr.checkpoints.flush(ctx); err != nil {
+		kr.setting.Logger.Error("error in persisting watch checkpoints", zap.Error(err))
+	}
+	return kr.checkpoints.client.Close(ctx)
+}
 
-	switch object.Mode {
-	case PullMode:
-		if len(object.Namespaces) == 0 {
-			go kr.startPull(ctx, object, resource)
-		} else {
-			for _, ns := range object.Namespaces {
-				go kr.startPull(ctx, object, resource.Namespace(ns))
+// startDiscovery resolves the resources of the cluster objects and starts
+// collecting them. Discovery is retried with backoff until it succeeds and
+// then repeated every discovery interval, so that objects whose resource
+// is installed later are picked up.
+func (kr *k8sobjectreceiver) startDiscovery(ctx context.Context, cluster *cluster, stopperChan chan struct{}) {
+	backoff := discoveryBackoff
+	for {
+		next := kr.discoveryInterval
+		if err := kr.discover(ctx, cluster); err != nil {
+			next = backoff.Step()
+			if next > kr.discoveryInterval {
+				next = kr.discoveryInterval
 			}
+			kr.setting.Logger.Warn("error in discovering resources, retrying", zap.String("cluster", cluster.name), zap.Duration("retry_in", next), zap.Error(err))
+		} else {
+			backoff = discoveryBackoff
 		}
 
-	case WatchMode:
-		if len(object.Namespaces) == 0 {
-			go kr.startWatch(ctx, object, resource)
-		} else {
-			for _, ns := range object.Namespaces {
-				go kr.startWatch(ctx, object, resource.Namespace(ns))
-			}
+		timer := time.NewTimer(next)
+		select {
+		case <-timer.C:
+		case <-stopperChan:
+			timer.Stop()
+			return
 		}
 	}
 }
 
-func (kr *k8sobjectreceiver) startPull(ctx context.Context, config *K8sObjectsConfig, resource dynamic.ResourceInterface) {
-	stopperChan := make(chan struct{})
-	kr.stopperChanList = append(kr.stopperChanList, stopperChan)
-	ticker := NewTicker(config.Interval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			objects, err := resource.List(ctx, metav1.ListOptions{
-				FieldSelector: config.FieldSelector,
-				LabelSelector: config.LabelSelector,
-			})
-			if err != nil {
-				kr.setting.Logger.Error("error in pulling object", zap.String("resource", config.gvr.String()), zap.Error(err))
-			} else if len(objects.Items) > 0 {
-				logs := unstructuredListToLogData(objects)
-				kr.consumer.ConsumeLogs(ctx, logs)
+func (kr *k8sobjectreceiver) discover(ctx context.Context, cluster *cluster) error {
+	if !cluster.pending() {
+		return nil
+	}
+
+	resources, err := cluster.config.getValidObjects(cluster.settings)
+	if err != nil {
+		return err
+	}
+	if len(resources.failedGroups) > 0 {
+		kr.setting.Logger.Warn("API discovery failed for some groups, using partial results", zap.String("cluster", cluster.name), zap.Strings("groups", resources.failedGroupNames()))
+	}
+
+	var issues permissionIssues
+	for _, object := range cluster.objects {
+		if cluster.skipped[object] || !object.isPattern() && len(cluster.started[object]) > 0 {
+			continue
+		}
+		resolved, err := resources.resolve(object)
+		if errors.Is(err, errAmbiguousResource) {
+			// Ambiguous references are configuration errors that later
+			// discoveries do not fix.
+			cluster.skipped[object] = true
+			if kr.permissionCheck == PermissionCheckStrict {
+				kr.setting.Logger.Error("error in resolving object", zap.String("cluster", cluster.name), zap.Error(err))
+				kr.host.ReportFatalError(fmt.Errorf("cluster %q: %w", cluster.name, err))
+				return nil
+			}
+			kr.setting.Logger.Error("error in resolving object, skipping", zap.String("cluster", cluster.name), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			kr.setting.Logger.Warn("error in resolving object, retrying on next discovery", zap.String("cluster", cluster.name), zap.Error(err))
+			continue
+		}
+		if cluster.started[object] == nil {
+			cluster.started[object] = make(map[schema.GroupVersionResource]bool)
+		}
+		for _, res := range resolved {
+			if cluster.started[object][res.gvr] {
+				continue
+			}
+			namespaces, objectIssues := kr.checkPermissions(ctx, cluster, object, res)
+			issues = append(issues, objectIssues...)
+			if len(objectIssues) > 0 && kr.permissionCheck == PermissionCheckStrict {
+				continue
+			}
+			// Objects that are skipped are not checked again.
+			cluster.started[object][res.gvr] = true
+			if len(namespaces) > 0 {
+				kr.start(ctx, cluster, object, res, namespaces)
 			}
-		case <-stopperChan:
-			return
 		}
+	}
 
+	if len(issues) > 0 {
+		if kr.permissionCheck == PermissionCheckStrict {
+			kr.setting.Logger.Error("missing permissions to collect objects", zap.String("cluster", cluster.name), zap.Array("issues", issues))
+			kr.host.ReportFatalError(fmt.Errorf("cluster %q: %w", cluster.name, issues))
+			return nil
+		}
+		kr.setting.Logger.Warn("missing permissions to collect objects, skipping", zap.String("cluster", cluster.name), zap.Array("issues", issues))
 	}
 
+	// Retry with backoff rather than waiting for the next discovery
+	// interval, the failed groups are usually available again shortly.
+	if len(resources.failedGroups) > 0 && cluster.pending() {
+		return fmt.Errorf("discovery failed for groups: %v", strings.Join(resources.failedGroupNames(), ", "))
+	}
+	return nil
 }
 
-func (kr *k8sobjectreceiver) startWatch(ctx context.Context, config *K8sObjectsConfig, resource dynamic.ResourceInterface) {
+// start collects an object from the namespaces, an empty namespace means
+// all namespaces. Objects with a namespace selector ignore the namespaces.
+func (kr *k8sobjectreceiver) start(ctx context.Context, cluster *cluster, object *K8sObjectsConfig, res apiResource, namespaces []string) {
+	gvr := res.gvr
+	kr.setting.Logger.Info("Started collecting object", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("mode", string(object.Mode)))
 
-	stopperChan := make(chan struct{})
-	kr.stopperChanList = append(kr.stopperChanList, stopperChan)
+	switch {
+	case object.selectsNamespaces(res):
+		target, err := newNamespaceTarget(object, func(namespace string) chan struct{} {
+			kr.setting.Logger.Debug("Started collecting object in namespace", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("namespace", namespace))
+			return kr.run(ctx, cluster, object, res, namespace)
+		})
+		if err != nil {
+			kr.setting.Logger.Error("error in parsing namespace selector", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+			return
+		}
+		if cluster.namespaces == nil {
+			cluster.namespaces = newNamespaceWatcher(cluster.client, kr.newStopperChan(), kr.closeStopperChan)
+		}
+		cluster.namespaces.add(target)
 
-	watch, err := resource.Watch(ctx, metav1.ListOptions{
-		FieldSelector: config.FieldSelector,
-		LabelSelector: config.LabelSelector,
-	})
-	if err != nil {
-		kr.setting.Logger.Error("error in watching object", zap.String("resource", config.gvr.String()), zap.Error(err))
-		return
+	default:
+		for _, ns := range namespaces {
+			kr.run(ctx, cluster, object, res, ns)
+		}
 	}
+}
 
-	res := watch.ResultChan()
-	for {
-		select {
-		case data := <-res:
-			logs := watchEventToLogData(data)
-			kr.consumer.ConsumeLogs(ctx, logs)
-		case <-stopperChan:
-			watch.Stop()
-			return
+// run starts collecting from a resource in a namespace, an empty namespace
+// means all namespaces, and returns the channel that stops it.
+func (kr *k8sobjectreceiver) run(ctx context.Context, cluster *cluster, object *K8sObjectsConfig, res apiResource, namespace string) chan struct{} {
+	object = object.forResource(res)
+	gvr := res.gvr
+	var resource objectResource
+	switch {
+	case object.Format == FormatTable:
+		resource = newTableResource(cluster.table, gvr, namespace, res.resource.Kind)
+	case object.Content == ContentMetadata && namespace != metav1.NamespaceAll:
+		resource = newMetadataResource(cluster.metadata.Resource(gvr).Namespace(namespace), gvr, res.resource.Kind)
+	case object.Content == ContentMetadata:
+		resource = newMetadataResource(cluster.metadata.Resource(gvr), gvr, res.resource.Kind)
+	case namespace != metav1.NamespaceAll:
+		resource = cluster.client.Resource(gvr).Namespace(namespace)
+	default:
+		resource = cluster.client.Resource(gvr)
+	}
+	stopperChan := kr.newStopperChan()
+	switch object.Mode {
+	case PullMode:
+		kr.goCollect(func() { kr.startPull(ctx, cluster, object, gvr, namespace, stopperChan, resource) })
+	case WatchMode:
+		if object.WatchBackend == WatchBackendInformer {
+			kr.startInformer(ctx, cluster, object, gvr, namespace, stopperChan)
+			break
 		}
+		kr.goCollect(func() { kr.startWatch(ctx, cluster, object, gvr, namespace, stopperChan, resource) })
 	}
+	return stopperChan
+}
 
+// newStopperChan registers a channel that is closed on Shutdown. The
+// channel is already closed if the receiver has been shut down.
+func (kr *k8sobjectreceiver) newStopperChan() chan struct{} {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	stopperChan := make(chan struct{})
+	if kr.stopped {
+		close(stopperChan)
+		return stopperChan
+	}
+	kr.stopperChanList = append(kr.stopperChanList, stopperChan)
+	return stopperChan
 }
 
-// Start ticking immediately.
-// Ref: https://stackoverflow.com/questions/32705582/how-to-get-time-tick-to-tick-immediately
-func NewTicker(repeat time.Duration) *time.Ticker {
-	ticker := time.NewTicker(repeat)
-	oc := ticker.C
-	nc := make(chan time.Time, 1)
+// goCollect runs a collection in a goroutine that Shutdown waits for.
+// Collections are not started once the receiver is stopped.
+func (kr *k8sobjectreceiver) goCollect(collect func()) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.stopped {
+		return
+	}
+	kr.collections.Add(1)
 	go func() {
-		nc <- time.Now()
-		for tm := range oc {
-			nc <- tm
-		}
+		defer kr.collections.Done()
+		collect()
 	}()
-	ticker.C = nc
-	return ticker
+}
+
+// closeStopperChan stops a single collection before Shutdown.
+func (kr *k8sobjectreceiver) closeStopperChan(stopperChan chan struct{}) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for i, c := range kr.stopperChanList {
+		if c == stopperChan {
+			kr.stopperChanList = append(kr.stopperChanList[:i], kr.stopperChanList[i+1:]...)
+			close(stopperChan)
+			return
+		}
+	}
 }
@@ -2,13 +2,18 @@ package k8sobjectreceiver
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestNewReceiver(t *testing.T) {
@@ -70,11 +75,55 @@ func TestPullObject(t *testing.T) {
 	require.NotNil(t, r)
 	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
 	time.Sleep(time.Second)
+	consumer.Lock()
 	assert.Len(t, consumer.Logs, 1)
 	assert.Equal(t, 2, consumer.Count)
+	consumer.Unlock()
 	assert.NoError(t, r.Shutdown(context.Background()))
 }
 
+func TestPullObjectMetadata(t *testing.T) {
+	t.Parallel()
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	rCfg.makeMetadataClient = newMockMetadataClient(
+		generatePodMetadata("pod1", "default", nil),
+		generatePodMetadata("pod2", "default", nil),
+	).getMockMetadataClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:     "pods",
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+			Content:  ContentMetadata,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+	time.Sleep(time.Second)
+	require.NoError(t, r.Shutdown(context.Background()))
+
+	consumer.Lock()
+	defer consumer.Unlock()
+	require.Len(t, consumer.Logs, 1)
+	assert.Equal(t, 2, consumer.Count)
+	logs := consumer.Logs[0].ResourceLogs().At(0)
+	kind, ok := logs.Resource().Attributes().Get("k8s.object.kind")
+	require.True(t, ok)
+	assert.Equal(t, "Pods", kind.StringVal())
+	body := logs.ScopeLogs().At(0).LogRecords().At(0).Body().MapVal()
+	_, ok = body.Get("metadata")
+	assert.True(t, ok)
+	assert.Equal(t, 3, body.Len())
+}
+
 func TestWatchObject(t *testing.T) {
 	t.Parallel()
 
@@ -121,8 +170,10 @@ func TestWatchObject(t *testing.T) {
 		}),
 	)
 	time.Sleep(time.Millisecond * 100)
+	consumer.Lock()
 	assert.Len(t, consumer.Logs, 2)
 	assert.Equal(t, 2, consumer.Count)
+	consumer.Unlock()
 
 	mockClient.createPods(
 		generatePod("pod4", "default", map[string]interface{}{
@@ -130,8 +181,463 @@ func TestWatchObject(t *testing.T) {
 		}),
 	)
 	time.Sleep(time.Millisecond * 100)
+	consumer.Lock()
 	assert.Len(t, consumer.Logs, 3)
 	assert.Equal(t, 3, consumer.Count)
+	consumer.Unlock()
 
 	assert.NoError(t, r.Shutdown(ctx))
 }
+
+func TestPullObjectMultiCluster(t *testing.T) {
+	t.Parallel()
+
+	productionClient := newMockDynamicClient()
+	productionClient.createPods(
+		generatePod("pod1", "default", map[string]interface{}{
+			"environment": "production",
+		}),
+	)
+	stagingClient := newMockDynamicClient()
+	stagingClient.createPods(
+		generatePod("pod1", "default", map[string]interface{}{
+			"environment": "staging",
+		}),
+		generatePod("pod2", "default", map[string]interface{}{
+			"environment": "staging",
+		}),
+	)
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+	rCfg.Clusters = []*ClusterConfig{
+		{
+			Name: "production",
+			Objects: []*K8sObjectsConfig{
+				{
+					Name:     "pods",
+					Mode:     PullMode,
+					Interval: time.Second * 30,
+				},
+			},
+			makeDynamicClient: productionClient.getMockDynamicClient,
+		},
+		{
+			Name: "staging",
+			Objects: []*K8sObjectsConfig{
+				{
+					Name:     "pods",
+					Mode:     PullMode,
+					Interval: time.Second * 30,
+				},
+			},
+			makeDynamicClient: stagingClient.getMockDynamicClient,
+		},
+	}
+
+	err := rCfg.Validate()
+	require.NoError(t, err)
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+	time.Sleep(time.Second)
+	assert.NoError(t, r.Shutdown(context.Background()))
+
+	consumer.Lock()
+	defer consumer.Unlock()
+	require.Len(t, consumer.Logs, 2)
+	counts := make(map[string]int)
+	for _, logs := range consumer.Logs {
+		clusterName, ok := logs.ResourceLogs().At(0).Resource().Attributes().Get("k8s.cluster.name")
+		require.True(t, ok)
+		counts[clusterName.StringVal()] += logs.LogRecordCount()
+	}
+	assert.Equal(t, map[string]int{"production": 1, "staging": 2}, counts)
+}
+
+func TestDiscoveryRetry(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(
+		generatePod("pod1", "default", map[string]interface{}{
+			"environment": "production",
+		}),
+	)
+	mockDiscovery := &MockDiscovery{failures: 1}
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = mockDiscovery.getMockDiscoveryClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:     "pods",
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+
+	time.Sleep(time.Millisecond * 100)
+	consumer.Lock()
+	assert.Equal(t, 0, consumer.Count)
+	consumer.Unlock()
+
+	time.Sleep(time.Millisecond * 1500)
+	consumer.Lock()
+	assert.Equal(t, 1, consumer.Count)
+	consumer.Unlock()
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestRediscoverResources(t *testing.T) {
+	t.Parallel()
+
+	certificates := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	mockClient := newMockDynamicClient()
+	mockClient.createObjects(certificates, &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"namespace": "default",
+				"name":      "example-com",
+			},
+		},
+	})
+	mockDiscovery := &MockDiscovery{}
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.DiscoveryInterval = time.Millisecond * 100
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = mockDiscovery.getMockDiscoveryClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:     "certificates.cert-manager.io",
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+
+	time.Sleep(time.Millisecond * 200)
+	consumer.Lock()
+	assert.Equal(t, 0, consumer.Count)
+	consumer.Unlock()
+
+	// The CRD is installed after the receiver started.
+	mockDiscovery.addResources(&metav1.APIResourceList{
+		GroupVersion: "cert-manager.io/v1",
+		APIResources: []metav1.APIResource{
+			{
+				Name: "certificates",
+				Kind: "Certificate",
+			},
+		},
+	})
+	time.Sleep(time.Millisecond * 300)
+	consumer.Lock()
+	assert.Equal(t, 1, consumer.Count)
+	consumer.Unlock()
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestPartialDiscovery(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(
+		generatePod("pod1", "default", map[string]interface{}{
+			"environment": "production",
+		}),
+	)
+	mockDiscovery := &MockDiscovery{
+		failedGroups: map[schema.GroupVersion]error{
+			{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("service unavailable"),
+		},
+	}
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = mockDiscovery.getMockDiscoveryClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:     "pods",
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+		},
+		{
+			Name:     "pods.metrics.k8s.io",
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+
+	time.Sleep(time.Millisecond * 100)
+	consumer.Lock()
+	assert.Equal(t, 1, consumer.Count)
+	consumer.Unlock()
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestPullObjectPattern(t *testing.T) {
+	t.Parallel()
+
+	certificates := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	mockClient := newMockDynamicClient()
+	mockClient.createObjects(certificates, &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"namespace": "default",
+				"name":      "example-com",
+			},
+		},
+	})
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClientWithCRDs
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:     "*.cert-manager.io",
+			Exclude:  []string{"issuers", "*.acme.cert-manager.io"},
+			Mode:     PullMode,
+			Interval: time.Second * 30,
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+
+	time.Sleep(time.Millisecond * 100)
+	consumer.Lock()
+	assert.Equal(t, 1, consumer.Count)
+	consumer.Unlock()
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestPullObjectNamespaceSelector(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	namespaces := mockClient.client.Resource(namespacesGVR)
+	for _, ns := range []*unstructured.Unstructured{
+		generateNamespace("team-a", map[string]interface{}{"tenant": "true"}),
+		generateNamespace("team-b", map[string]interface{}{}),
+		generateNamespace("team-c", map[string]interface{}{"tenant": "true"}),
+	} {
+		_, err := namespaces.Create(context.Background(), ns, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	mockClient.createPods(
+		generatePod("pod1", "team-a", map[string]interface{}{}),
+		generatePod("pod2", "team-b", map[string]interface{}{}),
+		generatePod("pod3", "team-c", map[string]interface{}{}),
+	)
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:              "pods",
+			Mode:              PullMode,
+			Interval:          time.Second * 30,
+			NamespaceSelector: "tenant=true",
+			ExcludeNamespaces: []string{"team-c"},
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+
+	time.Sleep(time.Millisecond * 200)
+	consumer.Lock()
+	assert.Equal(t, 1, consumer.Count)
+	consumer.Unlock()
+
+	// Collection starts when a namespace is relabeled to match.
+	_, err = namespaces.Update(context.Background(), generateNamespace("team-b", map[string]interface{}{"tenant": "true"}), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 200)
+	consumer.Lock()
+	assert.Equal(t, 2, consumer.Count)
+	consumer.Unlock()
+
+	// Collection stops when a namespace no longer matches or is deleted.
+	watcher := r.(*k8sobjectreceiver).clusters[0].namespaces
+	watcher.mu.Lock()
+	assert.Len(t, watcher.targets[0].running, 2)
+	watcher.mu.Unlock()
+
+	_, err = namespaces.Update(context.Background(), generateNamespace("team-a", map[string]interface{}{}), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, namespaces.Delete(context.Background(), "team-b", metav1.DeleteOptions{}))
+	time.Sleep(time.Millisecond * 200)
+	watcher.mu.Lock()
+	assert.Empty(t, watcher.targets[0].running)
+	watcher.mu.Unlock()
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestPermissionCheckStrict(t *testing.T) {
+	t.Parallel()
+
+	mockClient := newMockDynamicClient()
+	mockClient.createPods(
+		generatePod("pod1", "default", map[string]interface{}{
+			"environment": "production",
+		}),
+	)
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.PermissionCheck = PermissionCheckStrict
+	rCfg.makeDynamicClient = mockClient.getMockDynamicClient
+	rCfg.makeDiscoveryClient = getMockDiscoveryClient
+	rCfg.makeAccessReviewClient = newMockAccessReviewClient("kube-system/pods/list").getMockAccessReviewClient
+	rCfg.Objects = []*K8sObjectsConfig{
+		{
+			Name:       "pods",
+			Mode:       PullMode,
+			Interval:   time.Second * 30,
+			Namespaces: []string{"default", "kube-system"},
+		},
+	}
+	require.NoError(t, rCfg.Validate())
+
+	consumer := newMockLogConsumer()
+	r, err := newReceiver(
+		componenttest.NewNopReceiverCreateSettings(),
+		rCfg,
+		consumer,
+	)
+	require.NoError(t, err)
+
+	host := &fatalErrorHost{Host: componenttest.NewNopHost(), errs: make(chan error, 1)}
+	require.NoError(t, r.Start(context.Background(), host))
+	select {
+	case err := <-host.errs:
+		assert.EqualError(t, err, `cluster "": missing permissions: list pods in namespace kube-system: denied: RBAC: access denied`)
+	case <-time.After(time.Second):
+		t.Fatal("expected a fatal error")
+	}
+
+	consumer.Lock()
+	assert.Equal(t, 0, consumer.Count)
+	consumer.Unlock()
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestAmbiguousObject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		permissionCheck PermissionCheck
+		expectedErr     string
+	}{
+		{
+			name:            "strict",
+			permissionCheck: PermissionCheckStrict,
+			expectedErr:     `cluster "": ambiguous resource: events, set group to one of: core, events.k8s.io`,
+		},
+		{
+			name:            "lenient",
+			permissionCheck: PermissionCheckLenient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rCfg := createDefaultConfig().(*Config)
+			rCfg.PermissionCheck = tt.permissionCheck
+			rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+			rCfg.makeDiscoveryClient = getMockDiscoveryClientWithEvents
+			rCfg.makeAccessReviewClient = newMockAccessReviewClient().getMockAccessReviewClient
+			rCfg.Objects = []*K8sObjectsConfig{{Name: "events", Mode: WatchMode}}
+			require.NoError(t, rCfg.Validate())
+
+			r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+			require.NoError(t, err)
+			kr := r.(*k8sobjectreceiver)
+			host := &fatalErrorHost{Host: componenttest.NewNopHost(), errs: make(chan error, 1)}
+			kr.host = host
+
+			require.NoError(t, kr.discover(context.Background(), kr.clusters[0]))
+			select {
+			case err := <-host.errs:
+				assert.EqualError(t, err, tt.expectedErr)
+			default:
+				assert.Empty(t, tt.expectedErr, "expected a fatal error")
+			}
+			assert.False(t, kr.clusters[0].pending(), "ambiguous objects are not resolved again")
+		})
+	}
+}
+
+type fatalErrorHost struct {
+	component.Host
+	errs chan error
+}
+
+func (h *fatalErrorHost) ReportFatalError(err error) {
+	select {
+	case h.errs <- err:
+	default:
+	}
+}
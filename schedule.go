@@ -0,0 +1,134 @@
+package k8sobjectreceiver
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// InitialPull is when the first pull of an object happens.
+type InitialPull string
+
+const (
+	// InitialPullImmediate pulls as soon as the collection starts.
+	InitialPullImmediate InitialPull = "immediate"
+	// InitialPullStaggered delays the first pull by an offset within the
+	// interval that is derived from the cluster, resource and namespace, so
+	// that the pulls of different objects and namespaces are spread over
+	// the interval instead of hitting the API server at the same time.
+	InitialPullStaggered InitialPull = "staggered"
+	// InitialPullScheduled waits one interval, or for the first scheduled
+	// time, before the first pull.
+	InitialPullScheduled InitialPull = "scheduled"
+)
+
+var initialPullMap = map[InitialPull]bool{
+	InitialPullImmediate: true,
+	InitialPullStaggered: true,
+	InitialPullScheduled: true,
+}
+
+// pullSchedule computes when the pulls of a single collection happen.
+type pullSchedule struct {
+	interval time.Duration
+	// minInterval and maxInterval are set when the interval is adaptive.
+	minInterval time.Duration
+	maxInterval time.Duration
+	cron        cron.Schedule
+	jitter      time.Duration
+	initial     InitialPull
+	// offset delays the first pull of a staggered schedule.
+	offset time.Duration
+	// next is the time of the next pull before jitter is added.
+	next   time.Time
+	random func(n int64) int64
+}
+
+// newPullSchedule creates the schedule of an object, key identifies the
+// collection for staggering.
+func newPullSchedule(object *K8sObjectsConfig, key string) *pullSchedule {
+	s := &pullSchedule{
+		interval:    object.Interval,
+		minInterval: object.MinInterval,
+		maxInterval: object.MaxInterval,
+		jitter:      object.Jitter,
+		initial:     object.InitialPull,
+		random:      rand.Int63n,
+	}
+	if object.Schedule != "" {
+		// The schedule is validated with the config.
+		s.cron, _ = cron.ParseStandard(object.Schedule)
+	}
+	if s.initial == InitialPullStaggered && s.interval > 0 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		s.offset = time.Duration(h.Sum64() % uint64(s.interval))
+	}
+	return s
+}
+
+// first returns the time of the first pull. An immediate pull is not
+// delayed by jitter.
+func (s *pullSchedule) first(now time.Time) time.Time {
+	switch {
+	case s.initial == InitialPullImmediate:
+		s.next = now
+		return now
+	case s.initial == InitialPullStaggered:
+		s.next = now.Add(s.offset)
+	case s.cron != nil:
+		s.next = s.cron.Next(now)
+	default:
+		s.next = now.Add(s.interval)
+	}
+	return s.jittered(s.next)
+}
+
+// after returns the time of the pull following the current one. Pulls that
+// were missed, e.g. because a pull took longer than the interval, are
+// skipped. The zero time is returned when the schedule never fires again.
+func (s *pullSchedule) after(now time.Time) time.Time {
+	if s.cron != nil {
+		s.next = s.cron.Next(now)
+		if s.next.IsZero() {
+			return s.next
+		}
+		return s.jittered(s.next)
+	}
+	s.next = s.next.Add(s.interval)
+	for !s.next.After(now) {
+		s.next = s.next.Add(s.interval)
+	}
+	return s.jittered(s.next)
+}
+
+// adaptive returns whether the interval adapts to the changes of the objects.
+func (s *pullSchedule) adaptive() bool {
+	return s.maxInterval > 0
+}
+
+// adapt doubles the interval, up to the maximum interval, when the objects
+// did not change since the previous pull and resets it to the minimum
+// interval when they changed. It applies from the next pull on.
+func (s *pullSchedule) adapt(changed bool) {
+	if !s.adaptive() {
+		return
+	}
+	if changed {
+		s.interval = s.minInterval
+		return
+	}
+	s.interval *= 2
+	if s.interval > s.maxInterval {
+		s.interval = s.maxInterval
+	}
+}
+
+func (s *pullSchedule) jittered(t time.Time) time.Time {
+	if s.jitter <= 0 {
+		return t
+	}
+	return t.Add(time.Duration(s.random(int64(s.jitter))))
+}
@@ -0,0 +1,140 @@
+package k8sobjectreceiver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullSchedule(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 10, 20, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		object *K8sObjectsConfig
+		// times are the pull times returned by the schedule, each pull
+		// finishes a second after it started.
+		times []time.Time
+	}{
+		{
+			name:   "immediate",
+			object: &K8sObjectsConfig{Interval: time.Minute, InitialPull: InitialPullImmediate},
+			times:  []time.Time{now, now.Add(time.Minute), now.Add(2 * time.Minute)},
+		},
+		{
+			name:   "scheduled",
+			object: &K8sObjectsConfig{Interval: time.Minute, InitialPull: InitialPullScheduled},
+			times:  []time.Time{now.Add(time.Minute), now.Add(2 * time.Minute)},
+		},
+		{
+			name:   "jitter",
+			object: &K8sObjectsConfig{Interval: time.Minute, Jitter: 10 * time.Second, InitialPull: InitialPullScheduled},
+			// Jitter does not accumulate.
+			times: []time.Time{now.Add(time.Minute + 5*time.Second), now.Add(2*time.Minute + 5*time.Second)},
+		},
+		{
+			name:   "cron",
+			object: &K8sObjectsConfig{Schedule: "CRON_TZ=UTC 5 * * * *", InitialPull: InitialPullImmediate},
+			times: []time.Time{
+				now,
+				time.Date(2022, 8, 1, 11, 5, 0, 0, time.UTC),
+				time.Date(2022, 8, 1, 12, 5, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:   "cron scheduled with jitter",
+			object: &K8sObjectsConfig{Schedule: "CRON_TZ=UTC 5 * * * *", Jitter: 10 * time.Second, InitialPull: InitialPullScheduled},
+			times: []time.Time{
+				time.Date(2022, 8, 1, 11, 5, 5, 0, time.UTC),
+				time.Date(2022, 8, 1, 12, 5, 5, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newPullSchedule(tt.object, "cluster/pods")
+			s.random = func(n int64) int64 { return n / 2 }
+
+			times := []time.Time{s.first(now)}
+			for len(times) < len(tt.times) {
+				times = append(times, s.after(times[len(times)-1].Add(time.Second)))
+			}
+			for i := range tt.times {
+				assert.True(t, tt.times[i].Equal(times[i]), "pull %d: expected %v, got %v", i, tt.times[i], times[i])
+			}
+		})
+	}
+}
+
+func TestPullScheduleSkipsMissedPulls(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 10, 20, 0, 0, time.UTC)
+	s := newPullSchedule(&K8sObjectsConfig{Interval: time.Minute, InitialPull: InitialPullImmediate}, "cluster/pods")
+
+	require.Equal(t, now, s.first(now))
+	// A pull that takes two and a half intervals skips two pulls.
+	assert.Equal(t, now.Add(3*time.Minute), s.after(now.Add(150*time.Second)))
+}
+
+func TestPullScheduleStaggered(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 10, 20, 0, 0, time.UTC)
+	object := &K8sObjectsConfig{Interval: time.Minute, InitialPull: InitialPullStaggered}
+
+	first := newPullSchedule(object, "cluster/v1, Resource=pods/default").first(now)
+	assert.Equal(t, first, newPullSchedule(object, "cluster/v1, Resource=pods/default").first(now), "offset is stable for a collection")
+	assert.False(t, first.Before(now))
+	assert.True(t, first.Before(now.Add(time.Minute)))
+
+	offsets := make(map[time.Time]bool)
+	for _, namespace := range []string{"default", "kube-system", "kube-public", "monitoring"} {
+		offsets[newPullSchedule(object, "cluster/v1, Resource=pods/"+namespace).first(now)] = true
+	}
+	assert.Greater(t, len(offsets), 1, "namespaces are spread over the interval")
+}
+
+func TestPullScheduleAdaptive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 10, 20, 0, 0, time.UTC)
+	s := newPullSchedule(&K8sObjectsConfig{
+		Interval:    time.Minute,
+		MinInterval: time.Minute,
+		MaxInterval: 5 * time.Minute,
+		InitialPull: InitialPullImmediate,
+	}, "cluster/pods")
+	require.True(t, s.adaptive())
+
+	next := s.first(now)
+	for _, tt := range []struct {
+		changed  bool
+		interval time.Duration
+	}{
+		{changed: false, interval: 2 * time.Minute},
+		{changed: false, interval: 4 * time.Minute},
+		{changed: false, interval: 5 * time.Minute},
+		{changed: false, interval: 5 * time.Minute},
+		{changed: true, interval: time.Minute},
+		{changed: false, interval: 2 * time.Minute},
+	} {
+		s.adapt(tt.changed)
+		assert.Equal(t, tt.interval, s.interval)
+		prev := next
+		next = s.after(prev.Add(time.Second))
+		assert.Equal(t, tt.interval, next.Sub(prev))
+	}
+
+	fixed := newPullSchedule(&K8sObjectsConfig{Interval: time.Minute}, "cluster/pods")
+	fixed.adapt(false)
+	assert.False(t, fixed.adaptive())
+	assert.Equal(t, time.Minute, fixed.interval)
+}
@@ -0,0 +1,76 @@
+package k8sobjectreceiver
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.9.0"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// snapshotMarker is the type of the records that mark the boundaries of a
+// snapshot.
+type snapshotMarker string
+
+const (
+	snapshotBegin snapshotMarker = "SNAPSHOT_BEGIN"
+	snapshotEnd   snapshotMarker = "SNAPSHOT_END"
+	// watchResync is sent when a watch cannot resume from its resource
+	// version, the objects that follow are sent again as added.
+	watchResync snapshotMarker = "RESYNC"
+)
+
+// snapshot identifies the records of the objects of a single list. A list
+// that is restarted is a new snapshot.
+type snapshot struct {
+	id string
+	// resourceVersion is the resource version of the first page.
+	resourceVersion string
+	// count is the number of objects listed so far.
+	count int64
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{id: string(uuid.NewUUID())}
+}
+
+func (s *snapshot) putAttributes(attrs pcommon.Map) {
+	attrs.UpsertString("k8s.snapshot.id", s.id)
+	attrs.UpsertString("k8s.snapshot.resource_version", s.resourceVersion)
+}
+
+// snapshotMarkerToLogData creates a marker record of the snapshot of a list.
+// The object count of a begin marker is only known when the list is not
+// paginated or the server estimates the remaining items, a negative count
+// is omitted.
+func snapshotMarkerToLogData(snap *snapshot, marker snapshotMarker, count int64, list *unstructured.UnstructuredList, namespace string, clusterName string) plog.Logs {
+	out := plog.NewLogs()
+	rl := out.ResourceLogs().AppendEmpty()
+	sl := rl.ScopeLogs().AppendEmpty()
+
+	resourceAttrs := rl.Resource().Attributes()
+	resourceAttrs.EnsureCapacity(totalResourceAttributes)
+	resourceAttrs.UpsertString("k8s.object.kind", strings.TrimSuffix(list.GetKind(), "List"))
+	resourceAttrs.UpsertString("k8s.object.api_version", list.GetAPIVersion())
+	if clusterName != "" {
+		resourceAttrs.UpsertString(semconv.AttributeK8SClusterName, clusterName)
+	}
+
+	record := sl.LogRecords().AppendEmpty()
+	record.Body().SetEmptyMapVal().UpsertString("type", string(marker))
+
+	attrs := record.Attributes()
+	snap.putAttributes(attrs)
+	attrs.UpsertString("k8s.snapshot.marker", string(marker))
+	if count >= 0 {
+		attrs.UpsertInt("k8s.snapshot.object_count", count)
+	}
+	// The namespace is the scope of the snapshot, objects of other
+	// namespaces are not part of it.
+	if namespace != "" {
+		attrs.UpsertString(semconv.AttributeK8SNamespaceName, namespace)
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+// Format is how pull mode lists the objects.
+type Format string
+
+const (
+	// FormatObject lists the objects.
+	FormatObject Format = "object"
+	// FormatTable lists the objects as a server-side table, the format of
+	// kubectl get. Every row is sent with the metadata of its object and
+	// the printer columns of the resource as attributes.
+	FormatTable Format = "table"
+)
+
+var formatMap = map[Format]bool{
+	FormatObject: true,
+	FormatTable:  true,
+}
+
+// tableAcceptHeader requests a table, the server falls back to a list
+// when the resource cannot be printed as a table.
+const tableAcceptHeader = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
+// tableResource lists the objects of a resource as a table. Every item of
+// the returned lists only holds the apiVersion, kind and metadata of its
+// object, listTable also returns the printer columns of every item by name.
+type tableResource struct {
+	client     rest.Interface
+	gvr        schema.GroupVersionResource
+	namespace  string
+	apiVersion string
+	kind       string
+}
+
+func newTableResource(client rest.Interface, gvr schema.GroupVersionResource, namespace string, kind string) *tableResource {
+	return &tableResource{
+		client:     client,
+		gvr:        gvr,
+		namespace:  namespace,
+		apiVersion: gvr.GroupVersion().String(),
+		kind:       kind,
+	}
+}
+
+// path returns the path of the resource in the namespace.
+func (r *tableResource) path() []string {
+	segments := []string{"/apis", r.gvr.Group, r.gvr.Version}
+	if r.gvr.Group == "" {
+		segments = []string{"/api", r.gvr.Version}
+	}
+	if r.namespace != metav1.NamespaceAll {
+		segments = append(segments, "namespaces", r.namespace)
+	}
+	return append(segments, r.gvr.Resource)
+}
+
+func (r *tableResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, _, err := r.listTable(ctx, opts)
+	return list, err
+}
+
+// listTable lists the objects and the printer columns of every object, in
+// the order of the items of the list.
+func (r *tableResource) listTable(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, []map[string]interface{}, error) {
+	data, err := r.client.Get().
+		AbsPath(r.path()...).
+		SetHeader("Accept", tableAcceptHeader).
+		VersionedParams(&opts, metav1.ParameterCodec).
+		Param("includeObject", string(metav1.IncludeMetadata)).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	table := &metav1.Table{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, nil, err
+	}
+	if table.Kind != "Table" {
+		return nil, nil, fmt.Errorf("resource %v cannot be listed as a table", r.gvr.String())
+	}
+
+	out := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	out.SetAPIVersion(r.apiVersion)
+	out.SetKind(r.kind + "List")
+	out.SetResourceVersion(table.ResourceVersion)
+	out.SetContinue(table.Continue)
+	out.Items = make([]unstructured.Unstructured, 0, len(table.Rows))
+	columns := make([]map[string]interface{}, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		item, err := r.toUnstructured(row)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Items = append(out.Items, *item)
+		columns = append(columns, rowColumns(table.ColumnDefinitions, row))
+	}
+	return out, columns, nil
+}
+
+// Watch is not supported, tables are only used by pull mode.
+func (r *tableResource) Watch(context.Context, metav1.ListOptions) (watch.Interface, error) {
+	return nil, fmt.Errorf("watching resource %v as a table is not supported", r.gvr.String())
+}
+
+// toUnstructured converts the object of a row.
+func (r *tableResource) toUnstructured(row metav1.TableRow) (*unstructured.Unstructured, error) {
+	item := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	if row.Object.Raw != nil {
+		if err := json.Unmarshal(row.Object.Raw, &item.Object); err != nil {
+			return nil, err
+		}
+	}
+	item.SetAPIVersion(r.apiVersion)
+	item.SetKind(r.kind)
+	return item, nil
+}
+
+// rowColumns returns the columns of a row that kubectl get shows without
+// the wide output, i.e. with priority 0.
+func rowColumns(definitions []metav1.TableColumnDefinition, row metav1.TableRow) map[string]interface{} {
+	columns := make(map[string]interface{}, len(definitions))
+	for i, definition := range definitions {
+		if definition.Priority != 0 || i >= len(row.Cells) || row.Cells[i] == nil {
+			continue
+		}
+		cell := row.Cells[i]
+		// Cells are decoded from JSON, numbers of integer columns are kept
+		// as integers.
+		if number, ok := cell.(float64); ok && definition.Type == "integer" {
+			cell = int64(number)
+		}
+		columns[definition.Name] = cell
+	}
+	return columns
+}
+
+// tableColumnAttribute returns the log attribute of a printer column, e.g.
+// k8s.table.nominated_node for the Nominated Node column.
+func tableColumnAttribute(name string) string {
+	return "k8s.table." + strings.ReplaceAll(strings.ToLower(name), " ", "_")
+}
@@ -0,0 +1,172 @@
+package k8sobjectreceiver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/rest/fake"
+)
+
+const podsTable = `{
+  "kind": "Table",
+  "apiVersion": "meta.k8s.io/v1",
+  "metadata": {"resourceVersion": "1000", "continue": "next"},
+  "columnDefinitions": [
+    {"name": "Name", "type": "string", "format": "name"},
+    {"name": "Ready", "type": "string"},
+    {"name": "Status", "type": "string"},
+    {"name": "Restarts", "type": "integer"},
+    {"name": "Age", "type": "string"},
+    {"name": "IP", "type": "string", "priority": 1}
+  ],
+  "rows": [
+    {
+      "cells": ["pod1", "1/1", "Running", 2, "5d", "10.0.0.1"],
+      "object": {
+        "kind": "PartialObjectMetadata",
+        "apiVersion": "meta.k8s.io/v1",
+        "metadata": {"name": "pod1", "namespace": "default", "uid": "pod1-uid", "resourceVersion": "10"}
+      }
+    }
+  ]
+}`
+
+// newMockTableClient returns a client that responds to every request with
+// the body and records the requests.
+func newMockTableClient(body string, requests *[]*http.Request) rest.Interface {
+	return &fake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			*requests = append(*requests, req)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+}
+
+func TestTableResourceList(t *testing.T) {
+	t.Parallel()
+
+	var requests []*http.Request
+	resource := newTableResource(newMockTableClient(podsTable, &requests), podsGVR, "default", "Pod")
+
+	list, columns, err := resource.listTable(context.Background(), metav1.ListOptions{Limit: 10, LabelSelector: "app=web"})
+	require.NoError(t, err)
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/api/v1/namespaces/default/pods", requests[0].URL.Path)
+	assert.Equal(t, tableAcceptHeader, requests[0].Header.Get("Accept"))
+	assert.Equal(t, "Metadata", requests[0].URL.Query().Get("includeObject"))
+	assert.Equal(t, "10", requests[0].URL.Query().Get("limit"))
+	assert.Equal(t, "app=web", requests[0].URL.Query().Get("labelSelector"))
+
+	assert.Equal(t, "1000", list.GetResourceVersion())
+	assert.Equal(t, "next", list.GetContinue())
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "Pod", list.Items[0].GetKind())
+	assert.Equal(t, "v1", list.Items[0].GetAPIVersion())
+	assert.Equal(t, "pod1-uid", string(list.Items[0].GetUID()))
+
+	require.Len(t, columns, 1)
+	logs := listToLogData(list, nil, columns, nil, "")
+	record := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	attrs := record.Attributes().AsRaw()
+	assert.Equal(t, "pod1", attrs["k8s.table.name"])
+	assert.Equal(t, "1/1", attrs["k8s.table.ready"])
+	assert.Equal(t, "Running", attrs["k8s.table.status"])
+	assert.Equal(t, int64(2), attrs["k8s.table.restarts"])
+	assert.Equal(t, "5d", attrs["k8s.table.age"])
+	assert.NotContains(t, attrs, "k8s.table.ip", "wide columns are not collected")
+
+	body := record.Body().MapVal().AsRaw()
+	assert.Len(t, body, 3, "the columns are not in the body")
+	assert.Contains(t, body, "metadata")
+}
+
+func TestPullTableChanges(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	var requests []*http.Request
+	table := strings.Replace(podsTable, `"continue": "next"`, `"continue": ""`, 1)
+	resource := newTableResource(newMockTableClient(table, &requests), podsGVR, "default", "Pod")
+	object := &K8sObjectsConfig{Name: "pods", Mode: PullMode, Format: FormatTable, PullEmit: PullEmitChanges}
+	_, err = kr.pull(context.Background(), kr.clusters[0], object, podsGVR, resource, &pullState{changes: newPullChanges()})
+	require.NoError(t, err)
+
+	require.Len(t, consumer.Logs, 1)
+	attrs := consumer.Logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().AsRaw()
+	assert.Equal(t, "ADDED", attrs["k8s.object.change_type"])
+	assert.Equal(t, "Running", attrs["k8s.table.status"])
+}
+
+func TestTableResourcePath(t *testing.T) {
+	t.Parallel()
+
+	var requests []*http.Request
+	client := newMockTableClient(podsTable, &requests)
+
+	certificates := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	_, err := newTableResource(client, certificates, "", "Certificate").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "/apis/cert-manager.io/v1/certificates", requests[0].URL.Path)
+}
+
+func TestTableResourceNotSupported(t *testing.T) {
+	t.Parallel()
+
+	var requests []*http.Request
+	client := newMockTableClient(`{"kind": "PodList", "apiVersion": "v1", "metadata": {}, "items": []}`, &requests)
+
+	_, err := newTableResource(client, podsGVR, "", "Pod").List(context.Background(), metav1.ListOptions{})
+	assert.ErrorContains(t, err, "cannot be listed as a table")
+}
+
+func TestTableColumnAttribute(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "k8s.table.nominated_node", tableColumnAttribute("Nominated Node"))
+	assert.Equal(t, "k8s.table.ready", tableColumnAttribute("READY"))
+}
+
+func TestFullObjectColumnsField(t *testing.T) {
+	t.Parallel()
+
+	list := &unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{{
+			Object: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Report",
+				"metadata":   map[string]interface{}{"name": "report1"},
+				"columns":    map[string]interface{}{"Name": "name"},
+			},
+		}},
+	}
+	list.SetAPIVersion("example.com/v1")
+
+	logs := unstructuredListToLogData(list, "")
+	record := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	assert.NotContains(t, record.Attributes().AsRaw(), "k8s.table.name")
+	assert.Equal(t, map[string]interface{}{"Name": "name"}, record.Body().MapVal().AsRaw()["columns"])
+}
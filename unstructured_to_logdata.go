@@ -1,6 +1,8 @@
 package k8sobjectreceiver
 
 import (
+	"fmt"
+
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	semconv "go.opentelemetry.io/collector/semconv/v1.9.0"
@@ -10,46 +12,68 @@ import (
 
 const (
 	// Number of log attributes to add to the plog.LogRecordSlice.
-	totalLogAttributes = 3
+	totalLogAttributes = 7
 
 	// Number of resource attributes to add to the plog.ResourceLogs.
-	totalResourceAttributes = 2
+	totalResourceAttributes = 3
 )
 
-func watchEventToLogData(event watch.Event) plog.Logs {
-	udata := event.Object.(*unstructured.Unstructured)
+func watchEventToLogData(event watch.Event, clusterName string) plog.Logs {
+	return watchEventsToLogData([]watch.Event{event}, clusterName)
+}
+
+// watchEventsToLogData converts events of objects of the same resource.
+func watchEventsToLogData(events []watch.Event, clusterName string) plog.Logs {
 	out := plog.NewLogs()
 	rl := out.ResourceLogs().AppendEmpty()
 	sl := rl.ScopeLogs().AppendEmpty()
-	lr := sl.LogRecords().AppendEmpty()
-	dest := lr.Body()
 
 	resourceAttrs := rl.Resource().Attributes()
 	resourceAttrs.EnsureCapacity(totalResourceAttributes)
 
-	resourceAttrs.UpsertString("k8s.object.kind", udata.GetKind())
-	resourceAttrs.UpsertString("k8s.object.api_version", udata.GetAPIVersion())
-
-	destMap := dest.SetEmptyMapVal()
-	obj := map[string]interface{}{
-		"type":   string(event.Type),
-		"object": udata.Object,
+	first := events[0].Object.(*unstructured.Unstructured)
+	resourceAttrs.UpsertString("k8s.object.kind", first.GetKind())
+	resourceAttrs.UpsertString("k8s.object.api_version", first.GetAPIVersion())
+	if clusterName != "" {
+		resourceAttrs.UpsertString(semconv.AttributeK8SClusterName, clusterName)
 	}
-	toMap(obj).CopyTo(destMap)
 
-	attrs := lr.Attributes()
-	attrs.EnsureCapacity(totalLogAttributes)
+	logSlice := sl.LogRecords()
+	logSlice.EnsureCapacity(len(events))
+	for _, event := range events {
+		udata := event.Object.(*unstructured.Unstructured)
+		lr := logSlice.AppendEmpty()
+		dest := lr.Body()
 
-	attrs.UpsertString("k8s.object.name", udata.GetName())
-	attrs.UpsertString("k8s.object.resource_version", udata.GetResourceVersion())
-	if namespace := udata.GetNamespace(); namespace != "" {
-		attrs.UpsertString(semconv.AttributeK8SNamespaceName, namespace)
+		destMap := dest.SetEmptyMapVal()
+		obj := map[string]interface{}{
+			"type":   string(event.Type),
+			"object": udata.Object,
+		}
+		toMap(obj).CopyTo(destMap)
+
+		attrs := lr.Attributes()
+		attrs.EnsureCapacity(totalLogAttributes)
+
+		attrs.UpsertString("k8s.object.name", udata.GetName())
+		attrs.UpsertString("k8s.object.resource_version", udata.GetResourceVersion())
+		if namespace := udata.GetNamespace(); namespace != "" {
+			attrs.UpsertString(semconv.AttributeK8SNamespaceName, namespace)
+		}
 	}
 	return out
+}
 
+func unstructuredListToLogData(event *unstructured.UnstructuredList, clusterName string) plog.Logs {
+	return listToLogData(event, nil, nil, nil, clusterName)
 }
 
-func unstructuredListToLogData(event *unstructured.UnstructuredList) plog.Logs {
+// listToLogData converts the items of a list. With changeTypes, the items
+// changed between pulls and changeTypes holds the type of change of every
+// item. With columns, the items are table rows and columns holds the
+// printer columns of every item. With a snapshot, the records are stamped
+// with its id.
+func listToLogData(event *unstructured.UnstructuredList, changeTypes []watch.EventType, columns []map[string]interface{}, snap *snapshot, clusterName string) plog.Logs {
 	out := plog.NewLogs()
 	rl := out.ResourceLogs().AppendEmpty()
 	sl := rl.ScopeLogs().AppendEmpty()
@@ -59,19 +83,32 @@ func unstructuredListToLogData(event *unstructured.UnstructuredList) plog.Logs {
 
 	resourceAttrs.UpsertString("k8s.object.kind", event.Items[0].GetKind())
 	resourceAttrs.UpsertString("k8s.object.api_version", event.GetAPIVersion())
+	if clusterName != "" {
+		resourceAttrs.UpsertString(semconv.AttributeK8SClusterName, clusterName)
+	}
 
 	logSlice := sl.LogRecords()
 	logSlice.EnsureCapacity(len(event.Items))
-	for _, e := range event.Items {
+	for i, e := range event.Items {
 		record := logSlice.AppendEmpty()
 		attrs := record.Attributes()
 		attrs.EnsureCapacity(totalLogAttributes)
 
 		attrs.UpsertString("k8s.object.name", e.GetName())
 		attrs.UpsertString("k8s.object.resource_version", event.GetResourceVersion())
+		attrs.UpsertString("k8s.list.resource_version", event.GetResourceVersion())
 		if namespace := e.GetNamespace(); namespace != "" {
 			attrs.UpsertString(semconv.AttributeK8SNamespaceName, namespace)
 		}
+		if changeTypes != nil {
+			attrs.UpsertString("k8s.object.change_type", string(changeTypes[i]))
+		}
+		if snap != nil {
+			snap.putAttributes(attrs)
+		}
+		if columns != nil {
+			putTableColumns(attrs, columns[i])
+		}
 		dest := record.Body()
 		destMap := dest.SetEmptyMapVal()
 		toMap(e.Object).CopyTo(destMap)
@@ -79,6 +116,25 @@ func unstructuredListToLogData(event *unstructured.UnstructuredList) plog.Logs {
 	return out
 }
 
+// putTableColumns adds the printer columns of a table row as attributes.
+func putTableColumns(attrs pcommon.Map, columns map[string]interface{}) {
+	for name, value := range columns {
+		key := tableColumnAttribute(name)
+		switch value := value.(type) {
+		case string:
+			attrs.UpsertString(key, value)
+		case int64:
+			attrs.UpsertInt(key, value)
+		case float64:
+			attrs.UpsertDouble(key, value)
+		case bool:
+			attrs.UpsertBool(key, value)
+		default:
+			attrs.UpsertString(key, fmt.Sprint(value))
+		}
+	}
+}
+
 func toMap(objects map[string]interface{}) pcommon.Map {
 	val := pcommon.NewMapFromRaw(objects)
 	return val
@@ -0,0 +1,325 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchStart is how a watch starts.
+type WatchStart string
+
+const (
+	// WatchStartList lists the objects and sends them as added, then
+	// watches from the resource version of the list.
+	WatchStartList WatchStart = "list"
+	// WatchStartNow skips the existing objects and watches from the
+	// current resource version.
+	WatchStartNow WatchStart = "now"
+	// WatchStartResume watches from the checkpoint of the previous watch of
+	// the resource and namespace. Without a checkpoint, it starts like list.
+	WatchStartResume WatchStart = "resume"
+)
+
+var watchStartMap = map[WatchStart]bool{
+	WatchStartList:   true,
+	WatchStartNow:    true,
+	WatchStartResume: true,
+}
+
+// watchBackoff controls how failing watches are retried.
+var watchBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      5 * time.Minute,
+}
+
+// minHealthyWatch is how long a watch without events must have run to be
+// restarted without backoff.
+const minHealthyWatch = time.Minute
+
+// watchRestartReason is why a watch was restarted.
+type watchRestartReason string
+
+const (
+	// watchClosed is a watch whose stream was closed by the API server,
+	// which happens every few minutes.
+	watchClosed watchRestartReason = "closed"
+	// watchFailed is a watch that could not be established or ended with
+	// an error, or a relist that failed.
+	watchFailed watchRestartReason = "error"
+	// watchExpired is a watch whose resource version is too old, the
+	// objects are listed again before watching.
+	watchExpired watchRestartReason = "expired"
+)
+
+// startWatch watches a resource until the collection is stopped. The watch
+// starts from a list, the current resource version or a checkpoint as set
+// by watch_start. Watches that end are resumed from the last resource
+// version, with backoff when they fail. When the resource version expired,
+// the objects are listed again and sent as added before watching from the
+// resource version of the list, after a resync marker. The resource version
+// is checkpointed as the events are sent.
+func (kr *k8sobjectreceiver) startWatch(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, namespace string, stopperChan chan struct{}, resource objectResource) {
+	state := &watchState{
+		namespace: namespace,
+		key:       checkpointKey(cluster.name, gvr, namespace, config.LabelSelector, config.fieldSelector()),
+		mutators: []tag.Mutator{
+			tag.Upsert(tagReceiverKey, cluster.settings.receiverID.String()),
+			tag.Upsert(tagClusterKey, cluster.name),
+			tag.Upsert(tagResourceKey, gvr.String()),
+			tag.Upsert(tagNamespaceKey, namespace),
+		},
+	}
+	if config.Diff != "" {
+		state.versions = newObjectVersions()
+	}
+	backoff := watchBackoff
+	resourceVersion := ""
+	// relist is set while the watch needs the resource version of a list,
+	// emit whether the objects of the list are sent and resync whether they
+	// are sent again because the resource version expired.
+	relist, emit, resync := true, config.WatchStart != WatchStartNow, false
+	if config.WatchStart == WatchStartResume {
+		rv, ok, err := kr.checkpoints.get(ctx, state.key)
+		switch {
+		case err != nil:
+			kr.setting.Logger.Warn("error in reading checkpoint, listing objects", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("namespace", namespace), zap.Error(err))
+		case ok:
+			resourceVersion, relist = rv, false
+		default:
+			kr.setting.Logger.Info("no checkpoint to resume watch from, listing objects", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("namespace", namespace))
+		}
+	}
+	for {
+		var reason watchRestartReason
+		healthy := false
+		if relist {
+			rv, err := kr.relist(ctx, cluster, config, resource, state, emit, resync)
+			if err != nil {
+				kr.setting.Logger.Warn("error in listing object to start watch", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+				reason = watchFailed
+			} else {
+				resourceVersion = rv
+				kr.checkpoint(ctx, cluster, gvr, state.key, resourceVersion)
+				relist = false
+			}
+		}
+		if !relist {
+			resourceVersion, reason, healthy = kr.watch(ctx, cluster, config, gvr, resource, resourceVersion, stopperChan, state)
+			kr.checkpoint(ctx, cluster, gvr, state.key, resourceVersion)
+			if reason == "" {
+				return
+			}
+			// The objects are sent when the resource version expired, as
+			// changes since the resource version may have been missed.
+			relist = reason == watchExpired
+			emit, resync = true, relist
+		}
+		_ = stats.RecordWithTags(ctx, append(state.mutators, tag.Upsert(tagReasonKey, string(reason))), mWatchRestarts.M(1))
+
+		var delay time.Duration
+		switch {
+		case healthy:
+			backoff = watchBackoff
+		case reason != watchExpired:
+			delay = backoff.Step()
+		}
+		kr.setting.Logger.Debug("restarting watch", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("reason", string(reason)), zap.String("resource_version", resourceVersion), zap.Duration("retry_in", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-stopperChan:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// watch sends the events of a single watch from the resource version until
+// it ends. The resource version of every event is checkpointed, and
+// persisted on bookmarks. It returns the resource version to resume from, why the watch
+// ended, empty when it was stopped, and whether it was healthy, i.e. it
+// sent events or ran long enough.
+func (kr *k8sobjectreceiver) watch(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, resource objectResource, resourceVersion string, stopperChan chan struct{}, state *watchState) (string, watchRestartReason, bool) {
+	start := time.Now()
+	w, err := resource.Watch(ctx, metav1.ListOptions{
+		FieldSelector:       config.fieldSelector(),
+		LabelSelector:       config.LabelSelector,
+		ResourceVersion:     resourceVersion,
+		AllowWatchBookmarks: true,
+	})
+	if err != nil {
+		if isExpired(err) {
+			return resourceVersion, watchExpired, false
+		}
+		kr.setting.Logger.Warn("error in watching object", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+		return resourceVersion, watchFailed, false
+	}
+	defer w.Stop()
+
+	events := 0
+	res := w.ResultChan()
+	for {
+		select {
+		case event, ok := <-res:
+			if !ok {
+				return resourceVersion, watchClosed, events > 0 || time.Since(start) >= minHealthyWatch
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				object, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					kr.setting.Logger.Warn("unexpected object in watch event", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("type", string(event.Type)), zap.String("object", fmt.Sprintf("%T", event.Object)))
+					continue
+				}
+				events++
+				resourceVersion = object.GetResourceVersion()
+				kr.consumer.ConsumeLogs(ctx, kr.eventToLogData(cluster, config, gvr, event, state.versions))
+				kr.checkpoints.set(state.key, resourceVersion)
+			case watch.Bookmark:
+				// Bookmarks only carry the resource version to resume from.
+				if object, ok := event.Object.(*unstructured.Unstructured); ok {
+					events++
+					resourceVersion = object.GetResourceVersion()
+				}
+				if config.EmitBookmarks {
+					kr.emitEvent(ctx, cluster, gvr, event)
+				}
+				kr.checkpoint(ctx, cluster, gvr, state.key, resourceVersion)
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				reason := apierrors.ReasonForError(err)
+				if reason == metav1.StatusReasonUnknown {
+					reason = "Unknown"
+				}
+				_ = stats.RecordWithTags(ctx, append(state.mutators, tag.Upsert(tagReasonKey, string(reason))), mWatchErrors.M(1))
+				if config.EmitErrors {
+					kr.emitEvent(ctx, cluster, gvr, event)
+				}
+				if isExpired(err) {
+					return resourceVersion, watchExpired, false
+				}
+				fields := []zap.Field{zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("reason", string(reason)), zap.Error(err)}
+				if status, ok := err.(apierrors.APIStatus); ok {
+					fields = append(fields, zap.Int32("code", status.Status().Code))
+				}
+				kr.setting.Logger.Warn("error event in watching object", fields...)
+				return resourceVersion, watchFailed, false
+			default:
+				kr.setting.Logger.Debug("ignoring unknown watch event", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("type", string(event.Type)))
+			}
+		case <-stopperChan:
+			return resourceVersion, "", false
+		}
+	}
+}
+
+// watchState is kept between the watches of a collection.
+type watchState struct {
+	// namespace is the namespace of the collection, empty for all namespaces.
+	namespace string
+	// key identifies the checkpoint of the collection.
+	key      string
+	mutators []tag.Mutator
+	// versions is set when modified objects are sent with a diff.
+	versions *objectVersions
+}
+
+// eventToLogData converts the event of an object, recording its version
+// when modified objects are sent with a diff.
+func (kr *k8sobjectreceiver) eventToLogData(cluster *cluster, config *K8sObjectsConfig, gvr schema.GroupVersionResource, event watch.Event, versions *objectVersions) plog.Logs {
+	if versions == nil {
+		return watchEventToLogData(event, cluster.name)
+	}
+	previous := versions.update(event, event.Object.(*unstructured.Unstructured))
+	out, err := modifiedEventToLogData(config, event, previous, cluster.name)
+	if err != nil {
+		kr.setting.Logger.Warn("error in diffing object", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+	}
+	return out
+}
+
+// emitEvent sends a bookmark or error event as a record, the object of an
+// error event is a status.
+func (kr *k8sobjectreceiver) emitEvent(ctx context.Context, cluster *cluster, gvr schema.GroupVersionResource, event watch.Event) {
+	if _, ok := event.Object.(*unstructured.Unstructured); !ok {
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event.Object)
+		if err != nil {
+			kr.setting.Logger.Warn("error in converting watch event", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.String("type", string(event.Type)), zap.Error(err))
+			return
+		}
+		udata := &unstructured.Unstructured{Object: object}
+		if _, ok := event.Object.(*metav1.Status); ok && udata.GetKind() == "" {
+			udata.SetAPIVersion("v1")
+			udata.SetKind("Status")
+		}
+		event.Object = udata
+	}
+	kr.consumer.ConsumeLogs(ctx, watchEventToLogData(event, cluster.name))
+}
+
+// checkpoint records and persists the resource version of a watch.
+func (kr *k8sobjectreceiver) checkpoint(ctx context.Context, cluster *cluster, gvr schema.GroupVersionResource, key string, resourceVersion string) {
+	kr.checkpoints.set(key, resourceVersion)
+	if err := kr.checkpoints.flush(ctx); err != nil {
+		kr.setting.Logger.Warn("error in persisting watch checkpoint", zap.String("cluster", cluster.name), zap.String("resource", gvr.String()), zap.Error(err))
+	}
+}
+
+// relist lists the objects of the resource and returns the resource version
+// of the list. With emit, the objects are sent as added, otherwise only a
+// single object is requested for the resource version. With resync, the
+// objects follow a resync marker. The listed objects replace the versions
+// that modified objects are diffed with.
+func (kr *k8sobjectreceiver) relist(ctx context.Context, cluster *cluster, config *K8sObjectsConfig, resource objectResource, state *watchState, emit bool, resync bool) (string, error) {
+	opts := metav1.ListOptions{
+		FieldSelector: config.fieldSelector(),
+		LabelSelector: config.LabelSelector,
+	}
+	if !emit {
+		opts.Limit = 1
+	}
+	list, _, err := kr.list(ctx, cluster, resource, opts)
+	if err != nil {
+		return "", err
+	}
+	if emit && resync {
+		snap := newSnapshot()
+		snap.resourceVersion = list.GetResourceVersion()
+		kr.consumer.ConsumeLogs(ctx, snapshotMarkerToLogData(snap, watchResync, int64(len(list.Items)), list, state.namespace, cluster.name))
+	}
+	if emit && state.versions != nil {
+		state.versions.reset(list)
+	}
+	if emit && len(list.Items) > 0 {
+		events := make([]watch.Event, 0, len(list.Items))
+		for i := range list.Items {
+			events = append(events, watch.Event{Type: watch.Added, Object: &list.Items[i]})
+		}
+		kr.consumer.ConsumeLogs(ctx, watchEventsToLogData(events, cluster.name))
+	}
+	return list.GetResourceVersion(), nil
+}
+
+// isExpired returns whether a request failed because its resource version
+// or continue token is too old.
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
@@ -0,0 +1,300 @@
+package k8sobjectreceiver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// watchResult is returned by a call of watchResource.Watch.
+type watchResult struct {
+	watcher *watch.FakeWatcher
+	err     error
+}
+
+// watchResource returns its results to the watches in order, and records
+// the resource version of every watch. Lists return the pods with the
+// resource version 10.
+type watchResource struct {
+	dynamic.ResourceInterface
+	mu               sync.Mutex
+	results          chan watchResult
+	resourceVersions []string
+	lists            int
+	pods             []*unstructured.Unstructured
+}
+
+func (r *watchResource) Watch(_ context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	r.mu.Lock()
+	r.resourceVersions = append(r.resourceVersions, opts.ResourceVersion)
+	r.mu.Unlock()
+	result := <-r.results
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.watcher, nil
+}
+
+func (r *watchResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lists++
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+	list.SetResourceVersion("10")
+	for _, pod := range r.pods {
+		list.Items = append(list.Items, *pod)
+	}
+	return list, nil
+}
+
+func (r *watchResource) watches() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.resourceVersions...)
+}
+
+func TestWatchReconnect(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	resource := &watchResource{
+		results: make(chan watchResult),
+		pods:    []*unstructured.Unstructured{generateVersionedPod("pod1", "8"), generateVersionedPod("pod2", "9")},
+	}
+	stopperChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchStart: WatchStartNow}, podsGVR, "", stopperChan, resource)
+	}()
+
+	count := func() int {
+		consumer.Lock()
+		defer consumer.Unlock()
+		return consumer.Count
+	}
+
+	// The server closes the stream, the watch resumes from the last event.
+	first := watch.NewFake()
+	resource.results <- watchResult{watcher: first}
+	first.Add(generateVersionedPod("pod1", "5"))
+	first.Stop()
+
+	// The resource version expired, the objects are listed again after a
+	// resync marker.
+	second := watch.NewFake()
+	resource.results <- watchResult{watcher: second}
+	second.Error(&apierrors.NewGone("too old resource version").ErrStatus)
+	require.Eventually(t, func() bool { return count() == 4 }, time.Second, time.Millisecond)
+
+	// The watch fails and is retried with backoff from the list.
+	resource.results <- watchResult{err: errors.New("connection refused")}
+	third := watch.NewFake()
+	resource.results <- watchResult{watcher: third}
+	third.Modify(generateVersionedPod("pod2", "11"))
+	require.Eventually(t, func() bool { return count() == 5 }, time.Second, time.Millisecond)
+
+	close(stopperChan)
+	<-done
+
+	assert.Equal(t, []string{"10", "5", "10", "10"}, resource.watches())
+	assert.Equal(t, 2, resource.lists)
+
+	consumer.Lock()
+	defer consumer.Unlock()
+	require.Len(t, consumer.Logs, 4)
+	marker, ok := consumer.Logs[1].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().MapVal().Get("type")
+	require.True(t, ok)
+	assert.Equal(t, "RESYNC", marker.StringVal())
+	relisted := consumer.Logs[2].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	require.Equal(t, 2, relisted.Len())
+	for i := 0; i < relisted.Len(); i++ {
+		eventType, ok := relisted.At(i).Body().MapVal().Get("type")
+		require.True(t, ok)
+		assert.Equal(t, "ADDED", eventType.StringVal())
+	}
+}
+
+func TestWatchStopWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, newMockLogConsumer())
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	resource := &watchResource{results: make(chan watchResult, 1)}
+	resource.results <- watchResult{err: errors.New("connection refused")}
+	stopperChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode}, podsGVR, "", stopperChan, resource)
+	}()
+
+	require.Eventually(t, func() bool { return len(resource.watches()) == 1 }, time.Second, time.Millisecond)
+	close(stopperChan)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watch was not stopped during backoff")
+	}
+}
+
+func TestWatchBookmarksAndErrors(t *testing.T) {
+	t.Parallel()
+
+	consumer := newMockLogConsumer()
+	rCfg := createDefaultConfig().(*Config)
+	rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+	r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+	require.NoError(t, err)
+	kr := r.(*k8sobjectreceiver)
+
+	resource := &watchResource{results: make(chan watchResult)}
+	stopperChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchStart: WatchStartNow, EmitErrors: true}, podsGVR, "", stopperChan, resource)
+	}()
+
+	count := func() int {
+		consumer.Lock()
+		defer consumer.Unlock()
+		return consumer.Count
+	}
+
+	// Bookmarks are not sent, they only advance the resource version.
+	first := watch.NewFake()
+	resource.results <- watchResult{watcher: first}
+	first.Add(generateVersionedPod("pod1", "5"))
+	bookmark := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Pod"}}
+	bookmark.SetResourceVersion("7")
+	first.Action(watch.Bookmark, bookmark)
+	first.Error(&apierrors.NewInternalError(errors.New("etcd unavailable")).ErrStatus)
+	require.Eventually(t, func() bool { return count() == 2 }, time.Second, time.Millisecond)
+
+	second := watch.NewFake()
+	resource.results <- watchResult{watcher: second}
+	require.Eventually(t, func() bool { return len(resource.watches()) == 2 }, time.Second, time.Millisecond)
+
+	close(stopperChan)
+	<-done
+
+	assert.Equal(t, []string{"10", "7"}, resource.watches())
+
+	consumer.Lock()
+	defer consumer.Unlock()
+	require.Len(t, consumer.Logs, 2)
+	record := consumer.Logs[1].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	eventType, ok := record.Body().MapVal().Get("type")
+	require.True(t, ok)
+	assert.Equal(t, "ERROR", eventType.StringVal())
+	object, ok := record.Body().MapVal().Get("object")
+	require.True(t, ok)
+	reason, ok := object.MapVal().Get("reason")
+	require.True(t, ok)
+	assert.Equal(t, "InternalError", reason.StringVal())
+}
+
+func TestWatchStart(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		watchStart WatchStart
+		checkpoint string
+		watch      string
+		lists      int
+		records    int
+	}{
+		{
+			name:       "list",
+			watchStart: WatchStartList,
+			watch:      "10",
+			lists:      1,
+			records:    2,
+		},
+		{
+			name:       "now",
+			watchStart: WatchStartNow,
+			watch:      "10",
+			lists:      1,
+		},
+		{
+			name:       "resume",
+			watchStart: WatchStartResume,
+			checkpoint: "7",
+			watch:      "7",
+		},
+		{
+			name:       "resume_without_checkpoint",
+			watchStart: WatchStartResume,
+			watch:      "10",
+			lists:      1,
+			records:    2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			consumer := newMockLogConsumer()
+			rCfg := createDefaultConfig().(*Config)
+			rCfg.makeDynamicClient = newMockDynamicClient().getMockDynamicClient
+			r, err := newReceiver(componenttest.NewNopReceiverCreateSettings(), rCfg, consumer)
+			require.NoError(t, err)
+			kr := r.(*k8sobjectreceiver)
+			key := checkpointKey(kr.clusters[0].name, podsGVR, "", "", "")
+			kr.checkpoints.set(key, tt.checkpoint)
+
+			resource := &watchResource{
+				results: make(chan watchResult),
+				pods:    []*unstructured.Unstructured{generateVersionedPod("pod1", "8"), generateVersionedPod("pod2", "9")},
+			}
+			stopperChan := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				kr.startWatch(context.Background(), kr.clusters[0], &K8sObjectsConfig{Name: "pods", Mode: WatchMode, WatchStart: tt.watchStart}, podsGVR, "", stopperChan, resource)
+			}()
+
+			w := watch.NewFake()
+			resource.results <- watchResult{watcher: w}
+			w.Modify(generateVersionedPod("pod1", "12"))
+			close(stopperChan)
+			<-done
+
+			assert.Equal(t, []string{tt.watch}, resource.watches())
+			assert.Equal(t, tt.lists, resource.lists)
+			checkpoint, ok, err := kr.checkpoints.get(context.Background(), key)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "12", checkpoint)
+
+			consumer.Lock()
+			defer consumer.Unlock()
+			assert.Equal(t, tt.records+1, consumer.Count)
+		})
+	}
+}